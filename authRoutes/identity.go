@@ -0,0 +1,38 @@
+package authRoutes
+
+import (
+	"fmt"
+	"github/Somnathumapathi/gofrhack/engine"
+	"github/Somnathumapathi/gofrhack/models"
+	"net/http"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// SignedInUser returns the email of the user the request is signed in as, or a 401
+// error when it carries no valid bearer token
+func SignedInUser(ctx *gofr.Context) (string, error) {
+	email, _ := ctx.Value("userEmail").(string)
+	if email == "" {
+		return "", models.StatusError{Status: http.StatusUnauthorized, Message: "Not authorized. Please login!"}
+	}
+	return email, nil
+}
+
+// RequireWorkflowOwner returns the email of the signed-in user when they own the
+// workflow, a 401 error when no one is signed in and a 403 error otherwise
+func RequireWorkflowOwner(ctx *gofr.Context, workflowID int) (string, error) {
+	email, err := SignedInUser(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	owned, err := engine.WorkflowOwnedBy(ctx, ctx.SQL, workflowID, email)
+	if err != nil {
+		return "", err
+	}
+	if !owned {
+		return "", models.StatusError{Status: http.StatusForbidden, Message: fmt.Sprintf("workflow %d belongs to another user", workflowID)}
+	}
+	return email, nil
+}
@@ -0,0 +1,56 @@
+package authRoutes
+
+import (
+	"database/sql"
+	"fmt"
+	"github/Somnathumapathi/gofrhack/models"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// GetUserSettings returns the settings of the signed-in user
+func GetUserSettings(ctx *gofr.Context) (interface{}, error) {
+	email, err := SignedInUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings models.UserSettings
+	var limit sql.NullInt64
+	err = ctx.SQL.QueryRowContext(ctx, `SELECT max_concurrent_executions FROM users WHERE email = $1`, email).Scan(&limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user settings: %w", err)
+	}
+	if limit.Valid {
+		value := int(limit.Int64)
+		settings.MaxConcurrentExecutions = &value
+	}
+	return settings, nil
+}
+
+// UpdateUserSettings replaces the settings of the signed-in user. The concurrency
+// limit applies to runs of all of the user's workflows, and null removes it.
+func UpdateUserSettings(ctx *gofr.Context) (interface{}, error) {
+	email, err := SignedInUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings models.UserSettings
+	if err := ctx.Bind(&settings); err != nil {
+		return nil, fmt.Errorf("invalid request body: %w", err)
+	}
+	if settings.MaxConcurrentExecutions != nil && *settings.MaxConcurrentExecutions < 1 {
+		return nil, fmt.Errorf("maxConcurrentExecutions must be at least 1, or null for no limit")
+	}
+
+	result, err := ctx.SQL.ExecContext(ctx, `UPDATE users SET max_concurrent_executions = $1, updated_at = NOW() WHERE email = $2`,
+		settings.MaxConcurrentExecutions, email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user settings: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		return nil, fmt.Errorf("user %s not found", email)
+	}
+	return settings, nil
+}
@@ -2,6 +2,7 @@ package cronRoutes
 
 import (
 	"fmt"
+	"github/Somnathumapathi/gofrhack/engine"
 	"strconv"
 
 	"gofr.dev/pkg/gofr"
@@ -73,7 +74,7 @@ func GetWorkflowExecutions(ctx *gofr.Context) (interface{}, error) {
 	}
 
 	query := `
-		SELECT id, workflow_id, status, message, executed_at, duration_ms
+		SELECT id, workflow_id, status, message, executed_at, duration_ms, parent_execution_id, trigger_type
 		FROM workflow_executions
 		WHERE workflow_id = $1
 		ORDER BY executed_at DESC
@@ -94,6 +95,8 @@ func GetWorkflowExecutions(ctx *gofr.Context) (interface{}, error) {
 		Message    *string `json:"message"`
 		ExecutedAt string  `json:"executed_at"`
 		DurationMs *int    `json:"duration_ms"`
+		ParentID   *int    `json:"parent_execution_id"`
+		Trigger    *string `json:"trigger_type"`
 	}
 
 	var executions []WorkflowExecution
@@ -107,6 +110,8 @@ func GetWorkflowExecutions(ctx *gofr.Context) (interface{}, error) {
 			&execution.Message,
 			&execution.ExecutedAt,
 			&execution.DurationMs,
+			&execution.ParentID,
+			&execution.Trigger,
 		)
 		if err != nil {
 			ctx.Logger.Errorf("Error scanning execution row: %v", err)
@@ -159,3 +164,36 @@ func ToggleWorkflowSchedule(ctx *gofr.Context) (interface{}, error) {
 		"active":      requestBody.Active,
 	}, nil
 }
+
+// GetExecutionSteps returns every step attempt of an execution with its timings,
+// status, input, output and error
+func GetExecutionSteps(ctx *gofr.Context) (interface{}, error) {
+	workflowID, err := strconv.Atoi(ctx.PathParam("workflowId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow ID: %w", err)
+	}
+	executionID, err := strconv.Atoi(ctx.PathParam("executionId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid execution ID: %w", err)
+	}
+
+	execution, err := engine.LoadExecution(ctx, ctx.SQL, executionID)
+	if err != nil {
+		return nil, err
+	}
+	if execution.WorkflowID != workflowID {
+		return nil, fmt.Errorf("execution %d does not belong to workflow %d", executionID, workflowID)
+	}
+
+	steps, err := engine.ListStepExecutions(ctx, ctx.SQL, executionID)
+	if err != nil {
+		ctx.Logger.Errorf("Error querying step executions: %v", err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"execution": execution,
+		"steps":     steps,
+		"count":     len(steps),
+	}, nil
+}
@@ -0,0 +1,201 @@
+package deadLetterRoutes
+
+import (
+	"fmt"
+	"github/Somnathumapathi/gofrhack/authRoutes"
+	"github/Somnathumapathi/gofrhack/engine"
+	"strconv"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// maxReplayBatch bounds how many dead letters a bulk replay queues in one request
+const maxReplayBatch = 100
+
+// GetDeadLetters lists the failed runs of a workflow, optionally filtered by ?status=.
+// Dead-letter routes are only open to the signed-in owner of the workflow.
+func GetDeadLetters(ctx *gofr.Context) (interface{}, error) {
+	workflowID, err := strconv.Atoi(ctx.PathParam("workflowId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow ID: %w", err)
+	}
+	if _, err := authRoutes.RequireWorkflowOwner(ctx, workflowID); err != nil {
+		return nil, err
+	}
+
+	limit := 50
+	if limitStr := ctx.Param("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 1 || limit > 500 {
+			return nil, fmt.Errorf("limit must be between 1 and 500")
+		}
+	}
+
+	letters, err := engine.ListDeadLetters(ctx, ctx.SQL, workflowID, ctx.Param("status"), limit)
+	if err != nil {
+		ctx.Logger.Errorf("Error querying dead letters: %v", err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"deadLetters": letters,
+		"count":       len(letters),
+		"workflowId":  workflowID,
+	}, nil
+}
+
+// GetDeadLetter returns one failed run with its original trigger payload and headers
+func GetDeadLetter(ctx *gofr.Context) (interface{}, error) {
+	id, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid dead letter ID: %w", err)
+	}
+
+	return ownedDeadLetter(ctx, id)
+}
+
+// ReplayDeadLetter queues a failed run again against the current workflow definition.
+// The answer carries the new execution ID to poll at /executions/{id}.
+func ReplayDeadLetter(ctx *gofr.Context) (interface{}, error) {
+	id, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid dead letter ID: %w", err)
+	}
+
+	letter, err := ownedDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	workflow, err := engine.LoadWorkflow(ctx, ctx.SQL, letter.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	return replay(ctx, engine.NewQueue(engine.New(ctx.SQL, ctx.Logger)), *workflow, *letter), nil
+}
+
+// ReplayDeadLetters queues the dead letters listed in {"ids": [...]}, or every
+// pending dead letter of the workflow when no IDs are given. Workers run the replays
+// within the workflow's concurrency limits.
+func ReplayDeadLetters(ctx *gofr.Context) (interface{}, error) {
+	workflowID, err := strconv.Atoi(ctx.PathParam("workflowId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow ID: %w", err)
+	}
+	if _, err := authRoutes.RequireWorkflowOwner(ctx, workflowID); err != nil {
+		return nil, err
+	}
+
+	// An empty body replays every pending dead letter
+	var requestBody struct {
+		IDs []int `json:"ids"`
+	}
+	_ = ctx.Bind(&requestBody)
+	if len(requestBody.IDs) > maxReplayBatch {
+		return nil, fmt.Errorf("at most %d dead letters can be replayed at once", maxReplayBatch)
+	}
+
+	var letters []engine.DeadLetter
+	if len(requestBody.IDs) == 0 {
+		letters, err = engine.ListDeadLetters(ctx, ctx.SQL, workflowID, "pending", maxReplayBatch)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, id := range requestBody.IDs {
+			letter, err := engine.LoadDeadLetter(ctx, ctx.SQL, id)
+			if err != nil {
+				return nil, fmt.Errorf("dead letter %d: %w", id, err)
+			}
+			if letter.WorkflowID != workflowID {
+				return nil, fmt.Errorf("dead letter %d does not belong to workflow %d", id, workflowID)
+			}
+			letters = append(letters, *letter)
+		}
+	}
+
+	workflow, err := engine.LoadWorkflow(ctx, ctx.SQL, workflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Queue oldest first so runs happen in the order they originally arrived
+	queue := engine.NewQueue(engine.New(ctx.SQL, ctx.Logger))
+	results := make([]map[string]interface{}, 0, len(letters))
+	for i := len(letters) - 1; i >= 0; i-- {
+		results = append(results, replay(ctx, queue, *workflow, letters[i]))
+	}
+
+	queued := 0
+	for _, result := range results {
+		if result["status"] == "queued" {
+			queued++
+		}
+	}
+
+	return map[string]interface{}{
+		"replays":    results,
+		"count":      len(results),
+		"queued":     queued,
+		"rejected":   len(results) - queued,
+		"workflowId": workflowID,
+	}, nil
+}
+
+// DiscardDeadLetter deletes a failed run that should not be replayed
+func DiscardDeadLetter(ctx *gofr.Context) (interface{}, error) {
+	id, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid dead letter ID: %w", err)
+	}
+	if _, err := ownedDeadLetter(ctx, id); err != nil {
+		return nil, err
+	}
+
+	err = engine.DiscardDeadLetter(ctx, ctx.SQL, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"message": "Dead letter discarded",
+		"id":      id,
+	}, nil
+}
+
+// ownedDeadLetter loads a dead letter of a workflow owned by the signed-in user
+func ownedDeadLetter(ctx *gofr.Context, id int) (*engine.DeadLetter, error) {
+	if _, err := authRoutes.SignedInUser(ctx); err != nil {
+		return nil, err
+	}
+
+	letter, err := engine.LoadDeadLetter(ctx, ctx.SQL, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := authRoutes.RequireWorkflowOwner(ctx, letter.WorkflowID); err != nil {
+		return nil, err
+	}
+	return letter, nil
+}
+
+// replay queues one dead letter and reports the execution to poll for its outcome
+func replay(ctx *gofr.Context, queue *engine.Queue, workflow engine.Workflow, letter engine.DeadLetter) map[string]interface{} {
+	job, err := queue.Replay(ctx, workflow, letter)
+
+	response := map[string]interface{}{
+		"deadLetterId":        letter.ID,
+		"originalExecutionId": letter.ExecutionID,
+	}
+	if err != nil {
+		response["status"] = "rejected"
+		response["error"] = err.Error()
+		return response
+	}
+
+	response["status"] = "queued"
+	response["executionId"] = job.ExecutionID
+	response["statusUrl"] = fmt.Sprintf("/executions/%d", job.ExecutionID)
+	return response
+}
@@ -0,0 +1,343 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+func init() {
+	RegisterAction("database", databaseAction{})
+	RegisterAction("api_call", apiCallAction{})
+	RegisterAction("email", emailAction{})
+}
+
+// identifierPattern matches the table and column names a database action may use
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// databaseAction is meant to write the incoming record, or each record of a record
+// set, to a table. "operation" is insert (the default), update, which updates the
+// rows matching "keyColumns", or upsert, which updates the row conflicting on them.
+// "columns" limits which fields are written. It only logs the write for now, and
+// dry runs report the statements it would run.
+type databaseAction struct{}
+
+func (databaseAction) Validate(payload map[string]interface{}) error {
+	table, _ := payload["table"].(string)
+	if table == "" {
+		return fmt.Errorf("database action requires a table")
+	}
+	if !templated(table) && !identifierPattern.MatchString(table) {
+		return fmt.Errorf("invalid table name %q", table)
+	}
+
+	operation, _ := payload["operation"].(string)
+	switch operation {
+	case "", "insert":
+	case "update", "upsert":
+		if len(stringList(payload["keyColumns"])) == 0 {
+			return fmt.Errorf("%s requires keyColumns", operation)
+		}
+	default:
+		if !templated(operation) {
+			return fmt.Errorf("unsupported database operation %q", operation)
+		}
+	}
+
+	for _, key := range []string{"columns", "keyColumns"} {
+		for _, column := range stringList(payload[key]) {
+			if !identifierPattern.MatchString(column) {
+				return fmt.Errorf("invalid column name %q", column)
+			}
+		}
+	}
+	return nil
+}
+
+func (databaseAction) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	records, ok := Records(input)
+	if !ok {
+		records = []interface{}{input}
+	}
+
+	table, _ := step.Payload["table"].(string)
+	operation, _ := step.Payload["operation"].(string)
+
+	for i, record := range records {
+		query, args, err := writeStatement(step.Payload, record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		if exec.DryRun() {
+			exec.RecordEffect(ctx, Effect{Kind: "sql", SQL: query, Args: args})
+		}
+	}
+	if exec.DryRun() {
+		return input, nil
+	}
+
+	exec.Logger.Infof("Executing database action: %s on table %s", operation, table)
+
+	return input, nil
+}
+
+func (databaseAction) Describe() Description {
+	return Description{
+		Summary: "Logs the write of the incoming data to a table; nothing is written yet",
+		Output:  "The input, unchanged",
+	}
+}
+
+func (databaseAction) SupportsDryRun() bool {
+	return true
+}
+
+// writeStatement builds the parameterized INSERT, UPDATE or INSERT ... ON CONFLICT
+// that writes one record
+func writeStatement(payload map[string]interface{}, record interface{}) (string, []interface{}, error) {
+	fields, ok := record.(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("database action needs an object, got %T", record)
+	}
+
+	keys := stringList(payload["keyColumns"])
+	columns := stringList(payload["columns"])
+	if len(columns) == 0 {
+		for name := range fields {
+			columns = append(columns, name)
+		}
+		sort.Strings(columns)
+	}
+
+	operation, _ := payload["operation"].(string)
+	if operation == "update" {
+		// Key columns pick the rows to update rather than being written
+		isKey := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			isKey[key] = true
+		}
+		written := columns[:0:0]
+		for _, column := range columns {
+			if !isKey[column] {
+				written = append(written, column)
+			}
+		}
+		columns = written
+	}
+
+	var names, placeholders []string
+	var args []interface{}
+	for _, column := range columns {
+		if !identifierPattern.MatchString(column) {
+			return "", nil, fmt.Errorf("invalid column name %q", column)
+		}
+		value, err := columnValue(fields[column])
+		if err != nil {
+			return "", nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		names = append(names, quoteIdentifier(column))
+		args = append(args, value)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+	}
+	if len(names) == 0 {
+		return "", nil, fmt.Errorf("record has no columns to write")
+	}
+
+	table, _ := payload["table"].(string)
+	if !identifierPattern.MatchString(table) {
+		return "", nil, fmt.Errorf("invalid table name %q", table)
+	}
+
+	quotedKeys := make([]string, len(keys))
+	for i, key := range keys {
+		quotedKeys[i] = quoteIdentifier(key)
+	}
+
+	switch operation {
+	case "", "insert":
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdentifier(table),
+			strings.Join(names, ", "), strings.Join(placeholders, ", ")), args, nil
+
+	case "update":
+		sets := make([]string, len(names))
+		for i, name := range names {
+			sets[i] = fmt.Sprintf("%s = %s", name, placeholders[i])
+		}
+		conditions := make([]string, len(keys))
+		for i, key := range keys {
+			value, ok := fields[key]
+			if !ok {
+				return "", nil, fmt.Errorf("record has no value for key column %s", key)
+			}
+			value, err := columnValue(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("column %s: %w", key, err)
+			}
+			args = append(args, value)
+			conditions[i] = fmt.Sprintf("%s = $%d", quotedKeys[i], len(args))
+		}
+		return fmt.Sprintf("UPDATE %s SET %s WHERE %s", quoteIdentifier(table),
+			strings.Join(sets, ", "), strings.Join(conditions, " AND ")), args, nil
+
+	case "upsert":
+		updates := make([]string, len(names))
+		for i, name := range names {
+			updates[i] = fmt.Sprintf("%s = EXCLUDED.%s", name, name)
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s", quoteIdentifier(table),
+			strings.Join(names, ", "), strings.Join(placeholders, ", "),
+			strings.Join(quotedKeys, ", "), strings.Join(updates, ", ")), args, nil
+	}
+	return "", nil, fmt.Errorf("unsupported database operation %q", operation)
+}
+
+// quoteIdentifier quotes a validated table or column name, keeping a schema prefix
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + part + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+// columnValue stores nested objects and lists as JSON
+func columnValue(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	}
+	return value, nil
+}
+
+// stringList reads a list of strings from a payload value
+func stringList(value interface{}) []string {
+	list, _ := value.([]interface{})
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		if text, ok := item.(string); ok && text != "" {
+			result = append(result, text)
+		}
+	}
+	return result
+}
+
+// apiCallAction is meant to send the incoming data, or "body", as JSON to "url" with
+// "method" (POST by default) and "headers". It only logs the call for now, and dry
+// runs report the request it would send.
+type apiCallAction struct{}
+
+func (apiCallAction) Validate(payload map[string]interface{}) error {
+	if url, _ := payload["url"].(string); url == "" {
+		return fmt.Errorf("api_call action requires a url")
+	}
+	if headers, ok := payload["headers"]; ok {
+		if _, ok := headers.(map[string]interface{}); !ok {
+			return fmt.Errorf("headers must be an object")
+		}
+	}
+	return nil
+}
+
+func (apiCallAction) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	request := apiRequest(step.Payload, input)
+	if exec.DryRun() {
+		exec.RecordEffect(ctx, request)
+		return input, nil
+	}
+
+	exec.Logger.Infof("Executing API call: %s %s", request.Method, request.URL)
+
+	return input, nil
+}
+
+func (apiCallAction) Describe() Description {
+	return Description{
+		Summary: "Logs a call to an HTTP endpoint with the incoming data; no request is sent yet",
+		Output:  "The input, unchanged",
+	}
+}
+
+func (apiCallAction) SupportsDryRun() bool {
+	return true
+}
+
+// apiRequest is the request an api_call action sends: the incoming data, or "body",
+// as JSON to "url" with "method" and "headers"
+func apiRequest(payload map[string]interface{}, input interface{}) Effect {
+	method, _ := payload["method"].(string)
+	if method == "" {
+		method = http.MethodPost
+	}
+	url, _ := payload["url"].(string)
+
+	request := Effect{
+		Kind:    "http",
+		Method:  strings.ToUpper(method),
+		URL:     url,
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    input,
+	}
+	if body, ok := payload["body"]; ok {
+		request.Body = body
+	}
+	headers, _ := payload["headers"].(map[string]interface{})
+	for name, value := range headers {
+		request.Headers[http.CanonicalHeaderKey(name)] = toString(value)
+	}
+	return request
+}
+
+// emailAction sends a notification email
+type emailAction struct{}
+
+func (emailAction) Validate(payload map[string]interface{}) error {
+	if to, _ := payload["to"].(string); to == "" {
+		return fmt.Errorf("email action requires a recipient")
+	}
+	return nil
+}
+
+func (emailAction) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	to, _ := step.Payload["to"].(string)
+	subject, _ := step.Payload["subject"].(string)
+
+	if exec.DryRun() {
+		exec.RecordEffect(ctx, Effect{Kind: "email", To: to, Subject: subject})
+		return input, nil
+	}
+
+	exec.Logger.Infof("Sending email to: %s with subject: %s", to, subject)
+
+	return input, nil
+}
+
+func (emailAction) Describe() Description {
+	return Description{
+		Summary: "Sends an email notification",
+		Output:  "The input, unchanged",
+	}
+}
+
+func (emailAction) SupportsDryRun() bool {
+	return true
+}
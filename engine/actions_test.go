@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// testLogger sends engine logs to the test log
+type testLogger struct{ t *testing.T }
+
+func (l testLogger) Infof(format string, args ...interface{})  { l.t.Logf(format, args...) }
+func (l testLogger) Errorf(format string, args ...interface{}) { l.t.Logf(format, args...) }
+
+func TestDryRunActionEffects(t *testing.T) {
+	record := map[string]interface{}{"id": 7.0, "name": "Ada", "tags": []interface{}{"a"}}
+
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+		input   interface{}
+		want    []Effect
+	}{
+		{
+			name:    "insert",
+			payload: map[string]interface{}{"actionType": "database", "table": "public.people"},
+			input:   record,
+			want: []Effect{{
+				Kind: "sql",
+				SQL:  `INSERT INTO "public"."people" ("id", "name", "tags") VALUES ($1, $2, $3)`,
+				Args: []interface{}{7.0, "Ada", `["a"]`},
+			}},
+		},
+		{
+			name:    "insert of each record",
+			payload: map[string]interface{}{"actionType": "database", "table": "people", "columns": []interface{}{"name"}},
+			input:   []interface{}{record, map[string]interface{}{"name": "Grace"}},
+			want: []Effect{
+				{Kind: "sql", SQL: `INSERT INTO "people" ("name") VALUES ($1)`, Args: []interface{}{"Ada"}},
+				{Kind: "sql", SQL: `INSERT INTO "people" ("name") VALUES ($1)`, Args: []interface{}{"Grace"}},
+			},
+		},
+		{
+			name: "update",
+			payload: map[string]interface{}{"actionType": "database", "table": "people", "operation": "update",
+				"columns": []interface{}{"id", "name"}, "keyColumns": []interface{}{"id"}},
+			input: record,
+			want: []Effect{{
+				Kind: "sql",
+				SQL:  `UPDATE "people" SET "name" = $1 WHERE "id" = $2`,
+				Args: []interface{}{"Ada", 7.0},
+			}},
+		},
+		{
+			name: "upsert",
+			payload: map[string]interface{}{"actionType": "database", "table": "people", "operation": "upsert",
+				"columns": []interface{}{"id", "name"}, "keyColumns": []interface{}{"id"}},
+			input: record,
+			want: []Effect{{
+				Kind: "sql",
+				SQL:  `INSERT INTO "people" ("id", "name") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "id" = EXCLUDED."id", "name" = EXCLUDED."name"`,
+				Args: []interface{}{7.0, "Ada"},
+			}},
+		},
+		{
+			name:    "api call with defaults",
+			payload: map[string]interface{}{"actionType": "api_call", "url": "https://example.com/hook"},
+			input:   record,
+			want: []Effect{{
+				Kind:    "http",
+				Method:  "POST",
+				URL:     "https://example.com/hook",
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    record,
+			}},
+		},
+		{
+			name: "api call with method, headers and body",
+			payload: map[string]interface{}{"actionType": "api_call", "url": "https://example.com/people/{{ trigger.body.id }}",
+				"method": "put", "headers": map[string]interface{}{"x-token": "{{ trigger.body.name }}"},
+				"body": map[string]interface{}{"name": "{{ trigger.body.name }}"}},
+			input: record,
+			want: []Effect{{
+				Kind:    "http",
+				Method:  "PUT",
+				URL:     "https://example.com/people/7",
+				Headers: map[string]string{"Content-Type": "application/json", "X-Token": "Ada"},
+				Body:    map[string]interface{}{"name": "Ada"},
+			}},
+		},
+		{
+			name:    "email",
+			payload: map[string]interface{}{"actionType": "email", "to": "ops@example.com", "subject": "New person {{ trigger.body.name }}"},
+			input:   record,
+			want:    []Effect{{Kind: "email", To: "ops@example.com", Subject: "New person Ada"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := Workflow{ID: 1, Steps: []Step{
+				{Name: "start", Type: "trigger", Payload: map[string]interface{}{}},
+				{Name: "act", Type: "action", Payload: tt.payload},
+			}}
+			trigger := Trigger{Type: "webhook", Body: tt.input}
+
+			result := New(nil, testLogger{t}).DryRun(context.Background(), workflow, trigger)
+			if result.Status != "success" {
+				t.Fatalf("dry run %s: %s", result.Status, result.Error)
+			}
+
+			last := result.Steps[len(result.Steps)-1]
+			if last.Step != "act" {
+				t.Fatalf("last step traced is %q, want act", last.Step)
+			}
+			if !reflect.DeepEqual(last.Effects, tt.want) {
+				t.Errorf("effects = %#v\nwant %#v", last.Effects, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+)
+
+// branchStep routes its input to one of the step's named branches. Cases are checked
+// in order and the first match wins; when none match the default branch runs. A
+// single "condition" is shorthand for an if/else with "then" and "else" branches.
+type branchStep struct{}
+
+type branchCase struct {
+	name      string
+	condition Condition
+}
+
+func (branchStep) Validate(payload map[string]interface{}) error {
+	_, _, err := branchCases(payload)
+	return err
+}
+
+func (branchStep) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	cases, fallback, err := branchCases(step.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	chosen := fallback
+	for _, c := range cases {
+		matched, err := c.condition.Evaluate(input)
+		if err != nil {
+			return nil, fmt.Errorf("case %s: %w", c.name, err)
+		}
+		if matched {
+			chosen = c.name
+			break
+		}
+	}
+
+	sequence := step.Branches[chosen]
+	exec.Logger.Infof("Branch %s routed to %s (%d steps)", step.Name, chosen, len(sequence))
+
+	return exec.RunSteps(ctx, sequence, input)
+}
+
+func (branchStep) Describe() Description {
+	return Description{
+		Summary: `Routes the input to the first branch whose case matches ("cases": [{"name", "condition"}]) or to the default branch; "condition" alone gives then/else branches`,
+		Output:  "The output of the last step of the chosen branch",
+	}
+}
+
+func (branchStep) SupportsDryRun() bool {
+	return true
+}
+
+// branchCases reads the cases and the default branch name from a branch payload
+func branchCases(payload map[string]interface{}) ([]branchCase, string, error) {
+	if spec, ok := payload["condition"].(map[string]interface{}); ok {
+		condition, err := ParseCondition(spec)
+		if err != nil {
+			return nil, "", err
+		}
+		return []branchCase{{name: "then", condition: condition}}, "else", nil
+	}
+
+	list, ok := payload["cases"].([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, "", fmt.Errorf("branch requires a condition or a list of cases")
+	}
+
+	cases := make([]branchCase, 0, len(list))
+	for i, item := range list {
+		spec, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("case %d must be an object", i)
+		}
+		name, _ := spec["name"].(string)
+		if name == "" {
+			return nil, "", fmt.Errorf("case %d requires a name", i)
+		}
+		condition, err := ParseCondition(spec)
+		if err != nil {
+			return nil, "", fmt.Errorf("case %s: %w", name, err)
+		}
+		cases = append(cases, branchCase{name: name, condition: condition})
+	}
+
+	fallback, _ := payload["default"].(string)
+	if fallback == "" {
+		fallback = "default"
+	}
+
+	return cases, fallback, nil
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrCancelled is returned by runs stopped through CancelExecution
+var ErrCancelled = errors.New("cancelled")
+
+// running holds the cancel functions of the runs in this process by execution ID
+var running = struct {
+	sync.Mutex
+	runs map[int]context.CancelCauseFunc
+}{runs: make(map[int]context.CancelCauseFunc)}
+
+// watchCancel gives a run the context a cancellation is signalled through. Runs
+// started by another run are cancelled along with it. The returned function must be
+// called when the run is over.
+func watchCancel(exec *Execution) func() {
+	parent := exec.stop
+	if parent == nil {
+		parent = context.Background()
+	}
+	stop, cancel := context.WithCancelCause(parent)
+	exec.stop = stop
+
+	if exec.ID != 0 {
+		running.Lock()
+		running.runs[exec.ID] = cancel
+		running.Unlock()
+	}
+
+	return func() {
+		if exec.ID != 0 {
+			running.Lock()
+			delete(running.runs, exec.ID)
+			running.Unlock()
+		}
+		cancel(nil)
+	}
+}
+
+// cancelRun signals a run in this process to stop. It reports whether the run was found.
+func cancelRun(executionID int, cancelledBy string) bool {
+	running.Lock()
+	cancel, ok := running.runs[executionID]
+	running.Unlock()
+
+	if ok {
+		cancel(fmt.Errorf("%w by %s", ErrCancelled, cancelledBy))
+	}
+	return ok
+}
+
+// cancelled returns why the run was cancelled, or nil while it may go on
+func (e *Execution) cancelled() error {
+	if e.stop == nil || e.stop.Err() == nil {
+		return nil
+	}
+	return context.Cause(e.stop)
+}
+
+// stopped is closed when the run is cancelled
+func (e *Execution) stopped() <-chan struct{} {
+	if e.stop == nil {
+		return nil
+	}
+	return e.stop.Done()
+}
+
+// CancelExecution stops a run. A queued run is cancelled straight away and its job
+// dropped. A running one is stopped through the context of its current step, whose
+// cause is the cancellation, and is recorded as cancelled once the step returns. Runs on other instances notice the request at
+// their next lease heartbeat. It returns the run's status after the request.
+func CancelExecution(ctx context.Context, db DB, executionID int, cancelledBy string) (string, error) {
+	message := fmt.Sprintf("%v by %s", ErrCancelled, cancelledBy)
+
+	query := `UPDATE workflow_executions SET status = 'cancelled', message = $1, cancelled_by = $2, cancelled_at = NOW()
+		WHERE id = $3 AND status = 'queued'`
+	result, err := db.ExecContext(ctx, query, message, cancelledBy, executionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to cancel execution %d: %w", executionID, err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		_, err := db.ExecContext(ctx, `UPDATE jobs SET status = 'cancelled', updated_at = NOW()
+			WHERE execution_id = $1 AND status IN ('pending', 'queued')`, executionID)
+		if err != nil {
+			return "", fmt.Errorf("failed to drop the job of execution %d: %w", executionID, err)
+		}
+		if err := dropFiles(ctx, db, executionID); err != nil {
+			return "", fmt.Errorf("failed to drop uploaded files of execution %d: %w", executionID, err)
+		}
+		return "cancelled", nil
+	}
+
+	query = `UPDATE workflow_executions SET cancelled_by = $1, cancelled_at = NOW()
+		WHERE id = $2 AND status = 'running' AND cancelled_at IS NULL`
+	result, err = db.ExecContext(ctx, query, cancelledBy, executionID)
+	if err != nil {
+		return "", fmt.Errorf("failed to cancel execution %d: %w", executionID, err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		cancelRun(executionID, cancelledBy)
+		return "cancelling", nil
+	}
+
+	record, err := LoadExecution(ctx, db, executionID)
+	if err != nil {
+		return "", err
+	}
+	if record.Status == "running" {
+		return "cancelling", nil // already asked to stop
+	}
+	return "", fmt.Errorf("execution %d has already finished with status %s", executionID, record.Status)
+}
+
+// checkCancelled stops a run in this process whose cancellation was requested
+// through another instance
+func (e *Engine) checkCancelled(ctx context.Context, executionID int) {
+	var cancelledBy *string
+	query := `SELECT cancelled_by FROM workflow_executions WHERE id = $1`
+	if err := e.db.QueryRowContext(ctx, query, executionID).Scan(&cancelledBy); err != nil {
+		e.logger.Errorf("Failed to check whether execution %d was cancelled: %v", executionID, err)
+		return
+	}
+	if cancelledBy != nil {
+		cancelRun(executionID, *cancelledBy)
+	}
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// saveCheckpoint stores the output of one of the workflow's own steps so a failed run
+// can be resumed after it. Steps in branches and loop bodies are not checkpointed;
+// they run again with the step that contains them.
+func (e *Engine) saveCheckpoint(ctx context.Context, exec *Execution, step Step, output interface{}) {
+	if exec.ID == 0 || !exec.topLevel(step.Name) {
+		return
+	}
+
+	query := `INSERT INTO execution_checkpoints (execution_id, step_name, output_data) VALUES ($1, $2, $3)
+		ON CONFLICT (execution_id, step_name) DO UPDATE SET output_data = EXCLUDED.output_data, created_at = NOW()`
+	_, err := e.db.ExecContext(context.WithoutCancel(ctx), query, exec.ID, step.Name, jsonColumn(output))
+	if err != nil {
+		e.logger.Errorf("Failed to checkpoint step %s of execution %d: %v", step.Name, exec.ID, err)
+	}
+}
+
+// recordRestored records a step skipped by a resumed run along with the output it
+// restored, so the step history shows where the run picked up
+func (e *Engine) recordRestored(ctx context.Context, exec *Execution, step Step, input, output interface{}) {
+	query := `INSERT INTO step_executions (execution_id, step_id, step_name, attempt, status, input_data, output_data,
+		started_at, completed_at, duration_ms)
+		VALUES ($1, $2, $3, 0, 'restored', $4, $5, NOW(), NOW(), 0)`
+	_, err := e.db.ExecContext(context.WithoutCancel(ctx), query, exec.ID, step.ID, step.Name, jsonColumn(input), jsonColumn(output))
+	if err != nil {
+		e.logger.Errorf("Failed to record restored step %s: %v", step.Name, err)
+	}
+}
+
+// loadCheckpoints fetches the stored step outputs of an execution by step name
+func loadCheckpoints(ctx context.Context, db DB, executionID int) (map[string]interface{}, error) {
+	rows, err := db.QueryContext(ctx, `SELECT step_name, output_data FROM execution_checkpoints WHERE execution_id = $1`, executionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	checkpoints := make(map[string]interface{})
+	for rows.Next() {
+		var name string
+		var output []byte
+		if err := rows.Scan(&name, &output); err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		if len(output) > 0 {
+			if err := json.Unmarshal(output, &value); err != nil {
+				return nil, fmt.Errorf("invalid checkpoint of step %s: %w", name, err)
+			}
+		}
+		checkpoints[name] = value
+	}
+	return checkpoints, rows.Err()
+}
+
+// recordFailedStep stores which step a run failed at, or clears it
+func (e *Engine) recordFailedStep(ctx context.Context, executionID int, step *string) {
+	if executionID == 0 {
+		return
+	}
+	_, err := e.db.ExecContext(context.WithoutCancel(ctx), `UPDATE workflow_executions SET failed_step = $1 WHERE id = $2`, step, executionID)
+	if err != nil {
+		e.logger.Errorf("Failed to update execution %d: %v", executionID, err)
+	}
+}
+
+// SetStepPayload replaces the configuration of the named step, wherever it is nested.
+// It reports whether the step was found.
+func (w *Workflow) SetStepPayload(name string, payload map[string]interface{}) bool {
+	var set func(sequence []Step) bool
+	set = func(sequence []Step) bool {
+		for i := range sequence {
+			if sequence[i].Name == name {
+				sequence[i].Payload = payload
+				return true
+			}
+			for _, branch := range sequence[i].Branches {
+				if set(branch) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return set(w.Steps) || set(w.OnError)
+}
+
+// Resumable reports why a recorded run cannot be resumed, if it can't
+func (r *ExecutionRecord) Resumable() error {
+	if r.Status != "failed" && r.Status != "timed_out" {
+		return fmt.Errorf("execution %d is %s; only failed or timed out runs can be resumed", r.ID, r.Status)
+	}
+	if r.ParentExecutionID != nil {
+		return fmt.Errorf("execution %d was started by execution %d; resume that one instead", r.ID, *r.ParentExecutionID)
+	}
+	return nil
+}
+
+// Resume queues a failed execution to run again under the same ID. Steps whose output
+// was checkpointed are skipped and their output restored, so the run restarts at the
+// step that failed. stepConfig, keyed by step name, replaces the configuration of those
+// steps for this run only. The execution is moved back to queued in a single
+// statement, so of several concurrent requests only one resumes it.
+func (q *Queue) Resume(ctx context.Context, record *ExecutionRecord, workflow Workflow, stepConfig map[string]interface{}) (*Job, error) {
+	if err := record.Resumable(); err != nil {
+		return nil, err
+	}
+	if err := q.admit(ctx, workflow); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE workflow_executions SET status = 'queued' WHERE id = $1 AND status IN ('failed', 'timed_out')`
+	result, err := q.engine.db.ExecContext(ctx, query, record.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume execution %d: %w", record.ID, err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return nil, fmt.Errorf("execution %d is already being resumed", record.ID)
+	}
+
+	// Runs that did not go through the queue only have their trigger body to go on
+	fallback := Trigger{Body: record.Input}
+	if record.TriggerType != nil {
+		fallback.Type = *record.TriggerType
+	}
+
+	job := &Job{ExecutionID: record.ID, WorkflowID: workflow.ID, Kind: jobResume, StepConfig: stepConfig}
+	query = `INSERT INTO jobs (execution_id, workflow_id, trigger_data, kind, step_config, status, max_attempts)
+		VALUES ($1, $2, COALESCE((SELECT trigger_data FROM jobs WHERE execution_id = $1 ORDER BY id LIMIT 1), $3),
+			'resume', $4, 'queued', $5)
+		RETURNING id`
+	err = q.engine.db.QueryRowContext(ctx, query, record.ID, workflow.ID, jsonColumn(fallback), jsonColumn(stepConfig),
+		maxJobAttempts).Scan(&job.ID)
+	if err != nil {
+		// Leave the execution as it was so it can be resumed again
+		_, _ = q.engine.db.ExecContext(context.WithoutCancel(ctx),
+			`UPDATE workflow_executions SET status = $1 WHERE id = $2 AND status = 'queued'`, record.Status, record.ID)
+		return nil, fmt.Errorf("failed to queue resume of execution %d: %w", record.ID, err)
+	}
+	return job, nil
+}
+
+// resume runs a resume job: the job's execution continues from its checkpoints with
+// the trigger and uploaded files of its first run
+func (q *Queue) resume(ctx context.Context, job *Job, workflow Workflow) (interface{}, error) {
+	e := q.engine
+	for name, payload := range job.StepConfig {
+		if config, ok := payload.(map[string]interface{}); ok {
+			workflow.SetStepPayload(name, config)
+		}
+	}
+
+	record, err := LoadExecution(ctx, e.db, job.ExecutionID)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := loadCheckpoints(ctx, e.db, record.ID)
+	if err != nil {
+		e.finishExecution(ctx, record.ID, "failed", err.Error(), nil, time.Now())
+		return nil, err
+	}
+
+	trigger, err := q.jobTrigger(ctx, record)
+	defer trigger.RemoveFiles()
+	if err != nil {
+		e.finishExecution(ctx, record.ID, "failed", err.Error(), nil, time.Now())
+		return nil, err
+	}
+
+	exec := &Execution{
+		ID:       record.ID,
+		DB:       e.db,
+		Logger:   e.logger,
+		Workflow: workflow,
+		Trigger:  trigger,
+		engine:   e,
+		restored: checkpoints,
+	}
+	for name, output := range checkpoints {
+		exec.SetOutput(name, output)
+	}
+
+	// The failed run is being dealt with, so its dead letters are settled
+	query := `UPDATE dead_letters SET status = 'replayed', replayed_at = NOW(), replay_execution_id = $1
+		WHERE execution_id = $1 AND status = 'pending'`
+	if _, err := e.db.ExecContext(ctx, query, record.ID); err != nil {
+		e.logger.Errorf("Failed to settle dead letters of execution %d: %v", record.ID, err)
+	}
+	e.recordFailedStep(ctx, record.ID, nil)
+
+	e.logger.Infof("Resuming execution %d of workflow %d with %d checkpointed steps", record.ID, workflow.ID, len(checkpoints))
+	return e.run(ctx, exec)
+}
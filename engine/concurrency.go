@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrConcurrencyLimit is returned for runs turned away because their workflow or user
+// already has as many runs going as allowed
+var ErrConcurrencyLimit = errors.New("concurrency limit reached")
+
+// ErrSkipped is returned for runs of a singleton workflow dropped because a previous
+// run is still going
+var ErrSkipped = errors.New("a previous run is still going")
+
+// belowConcurrencyLimits is the claim condition for a job j of workflow w: neither the
+// workflow nor its user may have as many running jobs as their limit. The running
+// jobs are counted when the claim starts, so two instances claiming at the same
+// moment can briefly overshoot a limit.
+const belowConcurrencyLimits = `(
+	CASE WHEN COALESCE(w.settings->>'singleton', '') <> '' THEN 1
+		ELSE NULLIF(COALESCE((w.settings->>'maxConcurrency')::int, 0), 0) END IS NULL
+	OR (SELECT COUNT(*) FROM jobs r WHERE r.workflow_id = j.workflow_id AND r.status = 'running') <
+		CASE WHEN COALESCE(w.settings->>'singleton', '') <> '' THEN 1
+			ELSE (w.settings->>'maxConcurrency')::int END
+) AND (
+	w.user_id IS NULL
+	OR (SELECT u.max_concurrent_executions FROM users u WHERE u.id = w.user_id) IS NULL
+	OR (SELECT COUNT(*) FROM jobs r JOIN workflows rw ON rw.id = r.workflow_id
+		WHERE rw.user_id = w.user_id AND r.status = 'running') <
+		(SELECT u.max_concurrent_executions FROM users u WHERE u.id = w.user_id)
+)`
+
+// admit applies a workflow's concurrency settings to a new run. Singleton workflows
+// set to skip drop runs while another is queued or running, and workflows set to
+// reject overflow turn runs away while the workflow or its user is at its limit.
+// Everything else is queued and waits for a free slot when it is claimed.
+func (q *Queue) admit(ctx context.Context, workflow Workflow) error {
+	settings := workflow.Settings
+	if settings.Singleton != SingletonSkip && settings.Overflow != OverflowReject {
+		return nil
+	}
+
+	query := `SELECT
+		(SELECT COUNT(*) FROM jobs WHERE workflow_id = $1 AND status IN ('pending', 'queued', 'running')),
+		(SELECT COUNT(*) FROM jobs WHERE workflow_id = $1 AND status = 'running'),
+		(SELECT COUNT(*) FROM jobs r JOIN workflows w ON w.id = r.workflow_id WHERE w.user_id = $2 AND r.status = 'running'),
+		(SELECT max_concurrent_executions FROM users WHERE id = $2)`
+
+	var active, running, userRunning int
+	var userLimit sql.NullInt64
+	err := q.engine.db.QueryRowContext(ctx, query, workflow.ID, workflow.UserID).Scan(&active, &running, &userRunning, &userLimit)
+	if err != nil {
+		return fmt.Errorf("failed to check concurrency of workflow %d: %w", workflow.ID, err)
+	}
+
+	if settings.Singleton == SingletonSkip && active > 0 {
+		return ErrSkipped
+	}
+	if settings.Overflow == OverflowReject {
+		if limit := settings.concurrencyLimit(); limit > 0 && running >= limit {
+			return fmt.Errorf("%w: workflow %d allows %d concurrent runs", ErrConcurrencyLimit, workflow.ID, limit)
+		}
+		if userLimit.Valid && int64(userRunning) >= userLimit.Int64 {
+			return fmt.Errorf("%w: user %d allows %d concurrent runs", ErrConcurrencyLimit, workflow.UserID, userLimit.Int64)
+		}
+	}
+	return nil
+}
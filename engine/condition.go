@@ -0,0 +1,328 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Condition is a comparison on a field of the data, or an AND/OR group of conditions
+type Condition struct {
+	Field    string      `json:"field,omitempty"`
+	Operator string      `json:"operator,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+	And      []Condition `json:"and,omitempty"`
+	Or       []Condition `json:"or,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+var operators = map[string]bool{
+	"eq": true, "neq": true, "gt": true, "gte": true, "lt": true, "lte": true,
+	"contains": true, "in": true, "regex": true, "exists": true,
+	"before": true, "after": true, "on": true,
+}
+
+// ParseCondition reads a condition from a step payload. A payload holds either
+// field/operator/value, an "and" or "or" list of conditions, or a nested "condition".
+func ParseCondition(spec map[string]interface{}) (Condition, error) {
+	if nested, ok := spec["condition"].(map[string]interface{}); ok {
+		return ParseCondition(nested)
+	}
+
+	for _, key := range []string{"and", "or"} {
+		raw, ok := spec[key]
+		if !ok {
+			continue
+		}
+
+		list, ok := raw.([]interface{})
+		if !ok || len(list) == 0 {
+			return Condition{}, fmt.Errorf("%s must be a non-empty list of conditions", key)
+		}
+
+		group := make([]Condition, 0, len(list))
+		for _, item := range list {
+			itemSpec, ok := item.(map[string]interface{})
+			if !ok {
+				return Condition{}, fmt.Errorf("%s must be a list of conditions", key)
+			}
+			condition, err := ParseCondition(itemSpec)
+			if err != nil {
+				return Condition{}, err
+			}
+			group = append(group, condition)
+		}
+
+		if key == "and" {
+			return Condition{And: group}, nil
+		}
+		return Condition{Or: group}, nil
+	}
+
+	field, _ := spec["field"].(string)
+	if field == "" {
+		return Condition{}, fmt.Errorf("condition requires a field")
+	}
+	operator, _ := spec["operator"].(string)
+	if !operators[operator] {
+		return Condition{}, fmt.Errorf("unsupported operator %q", operator)
+	}
+
+	condition := Condition{Field: field, Operator: operator, Value: spec["value"]}
+	if templated(condition.Value) && operator != "regex" {
+		// Checked once the value is rendered, when the step runs
+		return condition, nil
+	}
+	switch operator {
+	case "regex":
+		expr, _ := condition.Value.(string)
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return Condition{}, fmt.Errorf("invalid regex for %s: %w", field, err)
+		}
+		condition.pattern = pattern
+	case "in":
+		if _, ok := condition.Value.([]interface{}); !ok {
+			return Condition{}, fmt.Errorf("operator in requires a list value for %s", field)
+		}
+	case "before", "after", "on":
+		if _, err := toTime(condition.Value); err != nil {
+			return Condition{}, fmt.Errorf("invalid date for %s: %w", field, err)
+		}
+	}
+
+	return condition, nil
+}
+
+// Evaluate reports whether the data satisfies the condition
+func (c Condition) Evaluate(data interface{}) (bool, error) {
+	if len(c.And) > 0 {
+		for _, condition := range c.And {
+			ok, err := condition.Evaluate(data)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	if len(c.Or) > 0 {
+		for _, condition := range c.Or {
+			ok, err := condition.Evaluate(data)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	actual, found := LookupPath(data, c.Field)
+	found = found && actual != nil
+
+	switch c.Operator {
+	case "exists":
+		want := true
+		if b, ok := c.Value.(bool); ok {
+			want = b
+		}
+		return found == want, nil
+	case "neq":
+		return !found || !equalValues(actual, c.Value), nil
+	}
+
+	if !found {
+		return false, nil
+	}
+
+	switch c.Operator {
+	case "eq":
+		return equalValues(actual, c.Value), nil
+	case "gt", "gte", "lt", "lte":
+		cmp := compareValues(actual, c.Value)
+		switch c.Operator {
+		case "gt":
+			return cmp > 0, nil
+		case "gte":
+			return cmp >= 0, nil
+		case "lt":
+			return cmp < 0, nil
+		default:
+			return cmp <= 0, nil
+		}
+	case "contains":
+		if list, ok := actual.([]interface{}); ok {
+			for _, item := range list {
+				if equalValues(item, c.Value) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(toString(actual), toString(c.Value)), nil
+	case "in":
+		list, _ := c.Value.([]interface{})
+		for _, item := range list {
+			if equalValues(actual, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "regex":
+		pattern := c.pattern
+		if pattern == nil {
+			var err error
+			if pattern, err = regexp.Compile(toString(c.Value)); err != nil {
+				return false, err
+			}
+		}
+		return pattern.MatchString(toString(actual)), nil
+	case "before", "after", "on":
+		actualTime, err := toTime(actual)
+		if err != nil {
+			// A value that is not a date never matches a date comparison
+			return false, nil
+		}
+		wantTime, err := toTime(c.Value)
+		if err != nil {
+			return false, err
+		}
+		switch c.Operator {
+		case "before":
+			return actualTime.Before(wantTime), nil
+		case "after":
+			return actualTime.After(wantTime), nil
+		default:
+			y1, m1, d1 := actualTime.UTC().Date()
+			y2, m2, d2 := wantTime.UTC().Date()
+			return y1 == y2 && m1 == m2 && d1 == d2, nil
+		}
+	}
+
+	return false, fmt.Errorf("unsupported operator %q", c.Operator)
+}
+
+// equalValues compares numerically when both values are numbers, otherwise as text
+func equalValues(a, b interface{}) bool {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+	if x, ok := a.(bool); ok {
+		if y, ok := b.(bool); ok {
+			return x == y
+		}
+	}
+	return toString(a) == toString(b)
+}
+
+// compareValues orders two values numerically when possible, otherwise as text
+func compareValues(a, b interface{}) int {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// toTime reads a date from a time value, a date string, unix seconds, or a relative
+// expression such as "now", "now-7d" or "now+2h"
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case int64:
+		return time.Unix(v, 0), nil
+	case string:
+		text := strings.TrimSpace(v)
+		if strings.HasPrefix(text, "now") {
+			return relativeTime(strings.TrimSpace(strings.TrimPrefix(text, "now")))
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognised date %q", text)
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %v", value)
+}
+
+// relativeTime applies an offset such as "-7d" or "+90m" to the current time
+func relativeTime(offset string) (time.Time, error) {
+	now := time.Now()
+	if offset == "" {
+		return now, nil
+	}
+
+	if strings.HasSuffix(offset, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(offset, "d"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid offset %q", offset)
+		}
+		return now.AddDate(0, 0, days), nil
+	}
+
+	duration, err := time.ParseDuration(offset)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid offset %q", offset)
+	}
+	return now.Add(duration), nil
+}
@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvOptions configures how a parse step reads CSV text
+type csvOptions struct {
+	Header      bool
+	Delimiter   rune
+	Quote       rune
+	SkipRows    int
+	Columns     []string
+	ColumnTypes map[string]string
+}
+
+// RowError reports a CSV row that could not be turned into a record
+type RowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// csvOptionsFromPayload reads CSV options from a parse step payload
+func csvOptionsFromPayload(payload map[string]interface{}) (csvOptions, error) {
+	opts := csvOptions{
+		Header:      true,
+		Delimiter:   ',',
+		Quote:       '"',
+		ColumnTypes: make(map[string]string),
+	}
+
+	if header, ok := payload["header"].(bool); ok {
+		opts.Header = header
+	}
+
+	if delimiter, ok := payload["delimiter"].(string); ok && delimiter != "" {
+		if delimiter == `\t` {
+			delimiter = "\t"
+		}
+		runes := []rune(delimiter)
+		if len(runes) != 1 {
+			return opts, fmt.Errorf("delimiter must be a single character")
+		}
+		opts.Delimiter = runes[0]
+	}
+
+	if quote, ok := payload["quote"].(string); ok && quote != "" {
+		runes := []rune(quote)
+		if len(runes) != 1 {
+			return opts, fmt.Errorf("quote must be a single character")
+		}
+		opts.Quote = runes[0]
+	}
+	if opts.Quote == opts.Delimiter {
+		return opts, fmt.Errorf("quote and delimiter must differ")
+	}
+
+	if skipRows, ok := payload["skipRows"].(float64); ok {
+		if skipRows < 0 {
+			return opts, fmt.Errorf("skipRows cannot be negative")
+		}
+		opts.SkipRows = int(skipRows)
+	}
+
+	if columns, ok := payload["columns"].([]interface{}); ok {
+		for _, column := range columns {
+			name, ok := column.(string)
+			if !ok {
+				return opts, fmt.Errorf("columns must be a list of names")
+			}
+			opts.Columns = append(opts.Columns, name)
+		}
+	}
+	if !opts.Header && len(opts.Columns) == 0 {
+		return opts, fmt.Errorf("columns are required when the CSV has no header row")
+	}
+
+	if columnTypes, ok := payload["columnTypes"].(map[string]interface{}); ok {
+		for column, hint := range columnTypes {
+			typeName, ok := hint.(string)
+			if !ok {
+				return opts, fmt.Errorf("type hint for column %s must be a string", column)
+			}
+			kind, _, _ := strings.Cut(typeName, ":")
+			switch kind {
+			case "string", "int", "float", "bool", "date":
+			default:
+				return opts, fmt.Errorf("unsupported type %q for column %s", typeName, column)
+			}
+			opts.ColumnTypes[column] = typeName
+		}
+	}
+
+	return opts, nil
+}
+
+// parseCSV turns CSV text into records keyed by column name. Rows that cannot be
+// converted are reported as row errors instead of aborting the parse.
+func parseCSV(text string, opts csvOptions) ([]interface{}, []RowError, error) {
+	rows, err := splitCSV(text, opts.Delimiter, opts.Quote)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.SkipRows >= len(rows) {
+		return []interface{}{}, []RowError{}, nil
+	}
+	rows = rows[opts.SkipRows:]
+
+	columns := opts.Columns
+	if opts.Header {
+		if len(columns) == 0 {
+			for _, name := range rows[0].fields {
+				columns = append(columns, strings.TrimSpace(name))
+			}
+		}
+		rows = rows[1:]
+	}
+
+	records := make([]interface{}, 0, len(rows))
+	rowErrors := make([]RowError, 0)
+	for _, row := range rows {
+		if len(row.fields) == 1 && strings.TrimSpace(row.fields[0]) == "" {
+			continue
+		}
+		if len(row.fields) != len(columns) {
+			rowErrors = append(rowErrors, RowError{
+				Row:   row.line,
+				Error: fmt.Sprintf("expected %d fields, got %d", len(columns), len(row.fields)),
+			})
+			continue
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		var convErr error
+		for i, column := range columns {
+			value, err := convertCSVValue(row.fields[i], opts.ColumnTypes[column])
+			if err != nil {
+				convErr = fmt.Errorf("column %s: %w", column, err)
+				break
+			}
+			record[column] = value
+		}
+		if convErr != nil {
+			rowErrors = append(rowErrors, RowError{Row: row.line, Error: convErr.Error()})
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records, rowErrors, nil
+}
+
+// convertCSVValue applies a column type hint such as "int", "bool" or "date:2006-01-02"
+func convertCSVValue(raw, hint string) (interface{}, error) {
+	if hint == "" || hint == "string" {
+		return raw, nil
+	}
+
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return nil, nil
+	}
+
+	kind, layout, _ := strings.Cut(hint, ":")
+	switch kind {
+	case "int":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int %q", value)
+		}
+		return n, nil
+	case "float":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float %q", value)
+		}
+		return f, nil
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool %q", value)
+		}
+		return b, nil
+	case "date":
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q for layout %s", value, layout)
+		}
+		return t, nil
+	}
+
+	return raw, nil
+}
+
+type csvRow struct {
+	line   int
+	fields []string
+}
+
+// splitCSV splits CSV text into rows of fields, honouring the quote character so
+// quoted fields may contain delimiters, newlines and doubled quotes
+func splitCSV(text string, delimiter, quote rune) ([]csvRow, error) {
+	var rows []csvRow
+	var fields []string
+	var field strings.Builder
+
+	line := 1
+	rowStart := 1
+	inQuotes := false
+	fieldQuoted := false
+
+	endField := func() {
+		fields = append(fields, field.String())
+		field.Reset()
+		fieldQuoted = false
+	}
+	endRow := func() {
+		endField()
+		rows = append(rows, csvRow{line: rowStart, fields: fields})
+		fields = nil
+	}
+
+	runes := []rune(strings.TrimPrefix(text, "\ufeff"))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		if inQuotes {
+			switch {
+			case r == quote && i+1 < len(runes) && runes[i+1] == quote:
+				field.WriteRune(quote)
+				i++
+			case r == quote:
+				inQuotes = false
+			default:
+				if r == '\n' {
+					line++
+				}
+				field.WriteRune(r)
+			}
+			continue
+		}
+
+		switch {
+		case r == quote && field.Len() == 0 && !fieldQuoted:
+			inQuotes = true
+			fieldQuoted = true
+		case r == delimiter:
+			endField()
+		case r == '\r' && i+1 < len(runes) && runes[i+1] == '\n':
+			// handled together with the following \n
+		case r == '\n' || r == '\r':
+			endRow()
+			line++
+			rowStart = line
+		default:
+			field.WriteRune(r)
+		}
+	}
+
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quoted field starting on line %d", rowStart)
+	}
+	if field.Len() > 0 || fieldQuoted || len(fields) > 0 {
+		endRow()
+	}
+
+	return rows, nil
+}
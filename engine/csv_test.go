@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitCSV(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		delimiter rune
+		want      []csvRow
+		wantErr   bool
+	}{
+		{
+			name:      "plain rows",
+			text:      "a,b\n1,2\n",
+			delimiter: ',',
+			want:      []csvRow{{line: 1, fields: []string{"a", "b"}}, {line: 2, fields: []string{"1", "2"}}},
+		},
+		{
+			name:      "CRLF line endings and no trailing newline",
+			text:      "a,b\r\n1,2",
+			delimiter: ',',
+			want:      []csvRow{{line: 1, fields: []string{"a", "b"}}, {line: 2, fields: []string{"1", "2"}}},
+		},
+		{
+			name:      "quoted delimiter, doubled quote and newline",
+			text:      "name,note\n\"Smith, J\",\"said \"\"hi\"\"\nthen left\"\nnext,row",
+			delimiter: ',',
+			want: []csvRow{
+				{line: 1, fields: []string{"name", "note"}},
+				{line: 2, fields: []string{"Smith, J", "said \"hi\"\nthen left"}},
+				{line: 4, fields: []string{"next", "row"}},
+			},
+		},
+		{
+			name:      "empty quoted field",
+			text:      "a,\"\",c",
+			delimiter: ',',
+			want:      []csvRow{{line: 1, fields: []string{"a", "", "c"}}},
+		},
+		{
+			name:      "byte order mark and tab delimiter",
+			text:      "\ufeffa\tb",
+			delimiter: '\t',
+			want:      []csvRow{{line: 1, fields: []string{"a", "b"}}},
+		},
+		{
+			name:      "quote inside an unquoted field is kept",
+			text:      `5" pipe,x`,
+			delimiter: ',',
+			want:      []csvRow{{line: 1, fields: []string{`5" pipe`, "x"}}},
+		},
+		{
+			name:      "unterminated quote",
+			text:      "a,\"open\nb",
+			delimiter: ',',
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitCSV(tt.text, tt.delimiter, '"')
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitCSV error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCSV = %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertCSVValue(t *testing.T) {
+	tests := []struct {
+		raw     string
+		hint    string
+		want    interface{}
+		wantErr bool
+	}{
+		{raw: " 42 ", hint: "", want: " 42 "},
+		{raw: "42", hint: "string", want: "42"},
+		{raw: " 42 ", hint: "int", want: int64(42)},
+		{raw: "4.5", hint: "int", wantErr: true},
+		{raw: "4.5", hint: "float", want: 4.5},
+		{raw: "abc", hint: "float", wantErr: true},
+		{raw: "true", hint: "bool", want: true},
+		{raw: "0", hint: "bool", want: false},
+		{raw: "yes", hint: "bool", wantErr: true},
+		{raw: "  ", hint: "int", want: nil},
+		{raw: "2024-03-01", hint: "date:2006-01-02", want: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{raw: "2024-03-01T10:00:00Z", hint: "date", want: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{raw: "01/03/2024", hint: "date:2006-01-02", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hint+"/"+tt.raw, func(t *testing.T) {
+			got, err := convertCSVValue(tt.raw, tt.hint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertCSVValue error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertCSVValue = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		payload    map[string]interface{}
+		wantRecs   []interface{}
+		wantErrors []RowError
+	}{
+		{
+			name:    "header row with type hints",
+			text:    "id,name,active\n1,Ada,true\n2,Grace,false\n",
+			payload: map[string]interface{}{"columnTypes": map[string]interface{}{"id": "int", "active": "bool"}},
+			wantRecs: []interface{}{
+				map[string]interface{}{"id": int64(1), "name": "Ada", "active": true},
+				map[string]interface{}{"id": int64(2), "name": "Grace", "active": false},
+			},
+			wantErrors: []RowError{},
+		},
+		{
+			name:    "columns without a header, skipped rows and blank lines",
+			text:    "exported today\n1;Ada\n\n2;Grace",
+			payload: map[string]interface{}{"header": false, "delimiter": ";", "skipRows": 1.0, "columns": []interface{}{"id", "name"}},
+			wantRecs: []interface{}{
+				map[string]interface{}{"id": "1", "name": "Ada"},
+				map[string]interface{}{"id": "2", "name": "Grace"},
+			},
+			wantErrors: []RowError{},
+		},
+		{
+			name:     "bad rows are reported and skipped",
+			text:     "id,name\n1,Ada\nx,Grace\n3\n",
+			payload:  map[string]interface{}{"columnTypes": map[string]interface{}{"id": "int"}},
+			wantRecs: []interface{}{map[string]interface{}{"id": int64(1), "name": "Ada"}},
+			wantErrors: []RowError{
+				{Row: 3, Error: `column id: invalid int "x"`},
+				{Row: 4, Error: "expected 2 fields, got 1"},
+			},
+		},
+		{
+			name:       "only skipped rows",
+			text:       "a\nb",
+			payload:    map[string]interface{}{"skipRows": 5.0},
+			wantRecs:   []interface{}{},
+			wantErrors: []RowError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := csvOptionsFromPayload(tt.payload)
+			if err != nil {
+				t.Fatalf("csvOptionsFromPayload: %v", err)
+			}
+			records, rowErrors, err := parseCSV(tt.text, opts)
+			if err != nil {
+				t.Fatalf("parseCSV: %v", err)
+			}
+			if !reflect.DeepEqual(records, tt.wantRecs) {
+				t.Errorf("records = %#v\nwant %#v", records, tt.wantRecs)
+			}
+			if !reflect.DeepEqual(rowErrors, tt.wantErrors) {
+				t.Errorf("row errors = %#v\nwant %#v", rowErrors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestCSVOptionsFromPayloadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload map[string]interface{}
+	}{
+		{"long delimiter", map[string]interface{}{"delimiter": ";;"}},
+		{"quote equals delimiter", map[string]interface{}{"quote": ","}},
+		{"negative skipRows", map[string]interface{}{"skipRows": -1.0}},
+		{"no header and no columns", map[string]interface{}{"header": false}},
+		{"non-string column", map[string]interface{}{"columns": []interface{}{1.0}}},
+		{"unknown type", map[string]interface{}{"columnTypes": map[string]interface{}{"id": "uuid"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := csvOptionsFromPayload(tt.payload); err == nil {
+				t.Error("csvOptionsFromPayload accepted an invalid payload")
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LookupPath resolves a nested path such as "lead.address.city" or "records[0].id"
+// against decoded JSON-like data. Array elements can also be addressed as "records.0".
+func LookupPath(data interface{}, path string) (interface{}, bool) {
+	segments, err := splitPath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	current := data
+	for _, segment := range segments {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			next, ok := value[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil, false
+			}
+			current = value[index]
+		case map[string]string:
+			next, ok := value[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// splitPath splits "a.b[0].c" into ["a", "b", "0", "c"]
+func splitPath(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, nil
+	}
+
+	var segments []string
+	for _, part := range strings.Split(path, ".") {
+		for part != "" {
+			open := strings.IndexByte(part, '[')
+			if open < 0 {
+				segments = append(segments, part)
+				break
+			}
+			if open > 0 {
+				segments = append(segments, part[:open])
+			}
+			end := strings.IndexByte(part[open:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in path %q", path)
+			}
+			segments = append(segments, strings.Trim(part[open+1:open+end], `"'`))
+			part = part[open+end+1:]
+		}
+	}
+
+	return segments, nil
+}
+
+// Records returns the records of a record set: either an array, or an object holding
+// the array under "records" as produced by parse steps and NDJSON webhooks.
+func Records(data interface{}) ([]interface{}, bool) {
+	switch value := data.(type) {
+	case []interface{}:
+		return value, true
+	case map[string]interface{}:
+		records, ok := value["records"].([]interface{})
+		return records, ok
+	}
+	return nil, false
+}
+
+// WithRecords returns a copy of a record set with its records replaced, keeping the
+// shape of the original data: an array stays an array, and an object keeps its other
+// fields
+func WithRecords(data interface{}, records []interface{}) interface{} {
+	value, ok := data.(map[string]interface{})
+	if !ok {
+		return records
+	}
+
+	result := make(map[string]interface{}, len(value))
+	for k, v := range value {
+		result[k] = v
+	}
+	result["records"] = records
+	return result
+}
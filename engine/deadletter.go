@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DeadLetter is a failed run kept with the trigger it was started with so it can be
+// inspected and replayed. Uploaded files stay with the failed run's job and are
+// restored when the dead letter is replayed. Credentials and signatures in the
+// trigger's headers are redacted.
+type DeadLetter struct {
+	ID                int        `json:"id"`
+	WorkflowID        int        `json:"workflowId"`
+	ExecutionID       int        `json:"executionId"`
+	Trigger           Trigger    `json:"trigger"`
+	FailedStep        string     `json:"failedStep,omitempty"`
+	Error             string     `json:"error"`
+	Status            string     `json:"status"` // pending, replayed
+	CreatedAt         time.Time  `json:"createdAt"`
+	ReplayedAt        *time.Time `json:"replayedAt,omitempty"`
+	ReplayExecutionID *int       `json:"replayExecutionId,omitempty"`
+}
+
+// deadLetter keeps a failed top-level run for replay. Runs started by other runs are
+// replayed through the run that started them.
+func (e *Engine) deadLetter(ctx context.Context, exec *Execution, runErr error) {
+	if exec.ParentID != 0 || exec.Trigger.Type == "error" || exec.DryRun() {
+		return
+	}
+
+	// Files are kept with the run's job rather than in the dead letter
+	trigger := exec.Trigger
+	trigger.Files = nil
+	trigger.Headers = redactHeaders(trigger.Headers)
+	triggerJSON, err := json.Marshal(trigger)
+	if err != nil {
+		e.logger.Errorf("Failed to encode trigger of execution %d for the dead-letter queue: %v", exec.ID, err)
+		return
+	}
+
+	var failedStep *string
+	if failed, ok := FailedStep(runErr); ok {
+		failedStep = &failed.Step
+	}
+
+	query := `INSERT INTO dead_letters (workflow_id, execution_id, trigger_data, failed_step, error_message)
+		VALUES ($1, $2, $3, $4, $5)`
+	_, err = e.db.ExecContext(context.WithoutCancel(ctx), query, exec.Workflow.ID, nullableID(exec.ID), string(triggerJSON), failedStep, runErr.Error())
+	if err != nil {
+		e.logger.Errorf("Failed to dead-letter execution %d: %v", exec.ID, err)
+	}
+}
+
+const deadLetterColumns = `id, workflow_id, execution_id, trigger_data, failed_step, error_message, status, created_at,
+	replayed_at, replay_execution_id`
+
+// ListDeadLetters returns the dead letters of a workflow, newest first. An empty
+// status lists them all.
+func ListDeadLetters(ctx context.Context, db DB, workflowID int, status string, limit int) ([]DeadLetter, error) {
+	query := `SELECT ` + deadLetterColumns + ` FROM dead_letters
+		WHERE workflow_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC LIMIT $3`
+	rows, err := db.QueryContext(ctx, query, workflowID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	letters := []DeadLetter{}
+	for rows.Next() {
+		letter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, *letter)
+	}
+	return letters, rows.Err()
+}
+
+// LoadDeadLetter fetches one dead letter
+func LoadDeadLetter(ctx context.Context, db DB, id int) (*DeadLetter, error) {
+	query := `SELECT ` + deadLetterColumns + ` FROM dead_letters WHERE id = $1`
+	letter, err := scanDeadLetter(db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("dead letter not found: %w", err)
+	}
+	return letter, nil
+}
+
+// DiscardDeadLetter deletes a dead letter
+func DiscardDeadLetter(ctx context.Context, db DB, id int) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM dead_letters WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to discard dead letter: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("dead letter %d not found", id)
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(row rowScanner) (*DeadLetter, error) {
+	var letter DeadLetter
+	var triggerJSON []byte
+	var executionID sql.NullInt64
+	var failedStep sql.NullString
+	var replayedAt sql.NullTime
+	var replayID sql.NullInt64
+
+	err := row.Scan(&letter.ID, &letter.WorkflowID, &executionID, &triggerJSON, &failedStep, &letter.Error,
+		&letter.Status, &letter.CreatedAt, &replayedAt, &replayID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(triggerJSON, &letter.Trigger); err != nil {
+		return nil, fmt.Errorf("invalid trigger for dead letter %d: %w", letter.ID, err)
+	}
+	letter.ExecutionID = int(executionID.Int64)
+	letter.FailedStep = failedStep.String
+	if replayedAt.Valid {
+		letter.ReplayedAt = &replayedAt.Time
+	}
+	if replayID.Valid {
+		id := int(replayID.Int64)
+		letter.ReplayExecutionID = &id
+	}
+
+	return &letter, nil
+}
@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// StepTrace is what one step did in a dry run
+type StepTrace struct {
+	Step       string                 `json:"step"`
+	Type       string                 `json:"type"`
+	Status     string                 `json:"status"` // success, failed, halted
+	Input      interface{}            `json:"input"`
+	Output     interface{}            `json:"output,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	Stats      map[string]interface{} `json:"stats,omitempty"`
+	Effects    []Effect               `json:"effects,omitempty"`
+	DurationMs int64                  `json:"durationMs"`
+}
+
+// Effect is a side effect an action would have had outside a dry run
+type Effect struct {
+	Kind    string            `json:"kind"` // sql, http, email
+	SQL     string            `json:"sql,omitempty"`
+	Args    []interface{}     `json:"args,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"`
+	To      string            `json:"to,omitempty"`
+	Subject string            `json:"subject,omitempty"`
+}
+
+// DryRunResult is the outcome of a dry run with the trace of every step it ran
+type DryRunResult struct {
+	Status string      `json:"status"` // success, halted, failed
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Steps  []StepTrace `json:"steps"`
+}
+
+// dryRun collects the step traces of a dry run, including those of loop iterations
+// and called workflows
+type dryRun struct {
+	mu    sync.Mutex
+	steps []*StepTrace
+}
+
+type stepTraceKey struct{}
+
+// DryRun runs a workflow against a sample trigger without side effects. Database,
+// api_call and email actions only report the SQL, HTTP request or email they would
+// have sent, steps are tried once, and nothing is recorded in the run history.
+func (e *Engine) DryRun(ctx context.Context, workflow Workflow, trigger Trigger) *DryRunResult {
+	trace := &dryRun{}
+	exec := &Execution{
+		DB:       e.db,
+		Logger:   e.logger,
+		Workflow: workflow,
+		Trigger:  trigger,
+		engine:   e,
+		trace:    trace,
+	}
+
+	result, err := e.run(ctx, exec)
+
+	outcome := &DryRunResult{Status: "success", Result: result, Steps: trace.list()}
+	switch {
+	case err != nil:
+		outcome.Status = "failed"
+		outcome.Error = err.Error()
+	case exec.halt != nil:
+		outcome.Status = "halted"
+	}
+	return outcome
+}
+
+// DryRun reports whether the execution is a dry run, in which actions must not have
+// side effects
+func (e *Execution) DryRun() bool {
+	return e.trace != nil
+}
+
+// begin starts the trace of a step attempt and returns a context carrying it for
+// RecordEffect. It does nothing outside a dry run.
+func (d *dryRun) begin(ctx context.Context, step Step, input interface{}) (context.Context, *StepTrace) {
+	if d == nil {
+		return ctx, nil
+	}
+
+	entry := &StepTrace{Step: step.Name, Type: step.Type, Input: input}
+	d.mu.Lock()
+	d.steps = append(d.steps, entry)
+	d.mu.Unlock()
+
+	return context.WithValue(ctx, stepTraceKey{}, entry), entry
+}
+
+// end completes the trace of a step attempt
+func (d *dryRun) end(entry *StepTrace, output interface{}, stats map[string]interface{}, err error, started time.Time) {
+	if d == nil || entry == nil {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry.DurationMs = time.Since(started).Milliseconds()
+	entry.Stats = stats
+	switch {
+	case errors.Is(err, ErrHalted):
+		entry.Status = "halted"
+		entry.Error = err.Error()
+	case err != nil:
+		entry.Status = "failed"
+		entry.Error = err.Error()
+	default:
+		entry.Status = "success"
+		entry.Output = output
+	}
+}
+
+// RecordEffect adds a side effect to the trace of the step running in ctx
+func (e *Execution) RecordEffect(ctx context.Context, effect Effect) {
+	entry, ok := ctx.Value(stepTraceKey{}).(*StepTrace)
+	if !ok || e.trace == nil {
+		return
+	}
+
+	e.trace.mu.Lock()
+	entry.Effects = append(entry.Effects, effect)
+	e.trace.mu.Unlock()
+}
+
+// list returns the traces in the order the steps started
+func (d *dryRun) list() []StepTrace {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	steps := make([]StepTrace, len(d.steps))
+	for i, entry := range d.steps {
+		steps[i] = *entry
+	}
+	return steps
+}
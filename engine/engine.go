@@ -0,0 +1,354 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrHalted is returned by a step to stop the workflow without failing it
+var ErrHalted = errors.New("workflow halted")
+
+// ErrTimedOut is returned when a step exceeds its timeout or a run exceeds its deadline
+var ErrTimedOut = errors.New("timed out")
+
+// StepFailure is the error of a failed step, carrying the input the step was given
+type StepFailure struct {
+	Step  string
+	Input interface{}
+	Err   error
+}
+
+func (f *StepFailure) Error() string {
+	return fmt.Sprintf("step '%s' failed: %v", f.Step, f.Err)
+}
+
+func (f *StepFailure) Unwrap() error { return f.Err }
+
+// FailedStep returns the innermost step failure of an error, such as the failing step
+// inside a branch rather than the branch step itself
+func FailedStep(err error) (*StepFailure, bool) {
+	var found, failure *StepFailure
+	for errors.As(err, &failure) {
+		found = failure
+		err = failure.Err
+	}
+	return found, found != nil
+}
+
+// Halt stops the workflow after the current step, recording why
+func Halt(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrHalted, fmt.Sprintf(format, args...))
+}
+
+// Trigger describes what started a workflow run and the data it was started with
+type Trigger struct {
+	Type        string            `json:"type"` // webhook, schedule, workflow
+	Body        interface{}       `json:"body"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ContentType string            `json:"contentType,omitempty"`
+	Raw         []byte            `json:"raw,omitempty"`
+	Files       map[string]File   `json:"files,omitempty"`
+}
+
+// File is an uploaded file spooled to temporary storage for the duration of a run
+type File struct {
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType"`
+	Path        string `json:"path"`
+}
+
+// RemoveFiles deletes the temporary copies of the trigger's uploaded files
+func (t Trigger) RemoveFiles() {
+	for _, file := range t.Files {
+		os.Remove(file.Path)
+	}
+}
+
+// Engine executes workflows. Webhook and scheduled runs share the same engine so a
+// workflow behaves the same no matter how it was triggered.
+type Engine struct {
+	db     DB
+	logger Logger
+}
+
+// New creates an engine backed by the given datasource and logger
+func New(db DB, logger Logger) *Engine {
+	return &Engine{db: db, logger: logger}
+}
+
+// Run executes the steps of a workflow in order, feeding each step the output of the
+// previous one. Every step's output is also kept by step name for later templates.
+// The run is recorded in workflow_executions.
+func (e *Engine) Run(ctx context.Context, workflow Workflow, trigger Trigger) (interface{}, error) {
+	exec := &Execution{
+		DB:       e.db,
+		Logger:   e.logger,
+		Workflow: workflow,
+		Trigger:  trigger,
+		engine:   e,
+	}
+
+	return e.run(ctx, exec)
+}
+
+// Start records a queued run of a workflow and returns its execution, so callers can
+// hand out the execution ID before the run begins. The job queue runs it.
+func (e *Engine) Start(ctx context.Context, workflow Workflow, trigger Trigger) *Execution {
+	exec := &Execution{
+		DB:       e.db,
+		Logger:   e.logger,
+		Workflow: workflow,
+		Trigger:  trigger,
+		engine:   e,
+	}
+	exec.ID = e.startExecution(ctx, exec, "queued")
+
+	return exec
+}
+
+// run records an execution, runs the workflow's steps within its deadline and stores
+// the outcome. Runs that exceed a timeout are recorded as timed_out, and runs stopped
+// through CancelExecution as cancelled. Dry runs are not recorded.
+func (e *Engine) run(ctx context.Context, exec *Execution) (interface{}, error) {
+	started := time.Now()
+	switch {
+	case exec.DryRun():
+		// Dry runs keep ID 0, so nothing about them is stored
+	case exec.ID == 0:
+		exec.ID = e.startExecution(ctx, exec, "running")
+	case !e.markRunning(ctx, exec.ID):
+		e.logger.Infof("Execution %d was cancelled before it started", exec.ID)
+		return nil, fmt.Errorf("execution %d was %w before it started", exec.ID, ErrCancelled)
+	}
+
+	done := watchCancel(exec)
+	defer done()
+
+	runCtx := ctx
+	if deadline := exec.Workflow.Settings.deadline(); deadline > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
+	}
+
+	result, err := exec.RunSteps(runCtx, exec.Workflow.Steps, exec.Trigger.Body)
+	if errors.Is(err, ErrHalted) {
+		e.logger.Infof("Workflow %d halted at step %s: %v", exec.Workflow.ID, exec.halt.step, err)
+		result = map[string]interface{}{
+			"halted":   true,
+			"haltedAt": exec.halt.step,
+			"reason":   exec.halt.reason,
+			"data":     exec.halt.data,
+		}
+		e.finishExecution(ctx, exec.ID, "halted", exec.halt.reason, result, started)
+		return result, nil
+	}
+	if errors.Is(err, ErrCancelled) {
+		reason := exec.cancelled()
+		if reason == nil {
+			reason = err
+		}
+		e.logger.Infof("Workflow %d execution %d %v", exec.Workflow.ID, exec.ID, reason)
+		e.finishExecution(ctx, exec.ID, "cancelled", reason.Error(), nil, started)
+		return nil, err
+	}
+	if err != nil {
+		status := "failed"
+		if errors.Is(err, ErrTimedOut) {
+			status = "timed_out"
+		}
+		if failed, ok := FailedStep(err); ok {
+			e.recordFailedStep(ctx, exec.ID, &failed.Step)
+		}
+		e.handleFailure(ctx, exec, err)
+		e.deadLetter(ctx, exec, err)
+		e.finishExecution(ctx, exec.ID, status, err.Error(), nil, started)
+		return nil, err
+	}
+
+	e.finishExecution(ctx, exec.ID, "success", fmt.Sprintf("%s execution completed", exec.Trigger.Type), result, started)
+	return result, nil
+}
+
+// runSequence executes steps one after another, each receiving the previous output.
+// A sequence in which any step declares dependencies runs as a graph instead.
+func (e *Engine) runSequence(ctx context.Context, exec *Execution, sequence []Step, data interface{}) (interface{}, error) {
+	if hasDependencies(sequence) {
+		return e.runGraph(ctx, exec, sequence, data)
+	}
+
+	for _, step := range sequence {
+		result, err := e.runStep(ctx, exec, step, data)
+		if err != nil {
+			return nil, err
+		}
+		data = result
+	}
+
+	return data, nil
+}
+
+// runStep executes one step, retrying it as its retry policy allows, and records its
+// output, or where the workflow halted. Every attempt is recorded in step_executions
+// and is bounded by the step's "timeout", such as "30s".
+func (e *Engine) runStep(ctx context.Context, exec *Execution, step Step, data interface{}) (interface{}, error) {
+	if output, ok := exec.restored[step.Name]; ok && exec.topLevel(step.Name) {
+		e.logger.Infof("Skipping step %s, its output was restored from a checkpoint", step.Name)
+		e.recordRestored(ctx, exec, step, data, output)
+		return output, nil
+	}
+	if err := exec.cancelled(); err != nil {
+		return nil, err
+	}
+
+	e.logger.Infof("Executing step: %s (Type: %s)", step.Name, step.Type)
+
+	policy, err := retryPolicyFromPayload(step.Payload)
+	if err != nil {
+		return nil, &StepFailure{Step: step.Name, Input: data, Err: err}
+	}
+	timeout, err := stepTimeout(exec, step, data)
+	if err != nil {
+		return nil, &StepFailure{Step: step.Name, Input: data, Err: err}
+	}
+	if exec.DryRun() {
+		policy = noRetry
+	}
+
+	var result interface{}
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		attemptCtx, trace := exec.trace.begin(ctx, step, data)
+		attemptCtx, stats := withStepStats(attemptCtx)
+		result, err = e.attemptStep(attemptCtx, exec, step, data, timeout)
+		reported := stats.snapshot()
+		exec.trace.end(trace, result, reported, err, started)
+		e.recordAttempt(ctx, exec, step, attempt, data, result, reported, err, started)
+
+		if err == nil || errors.Is(err, ErrHalted) || attempt >= policy.MaxAttempts || !policy.retryable(err) ||
+			ctx.Err() != nil || exec.cancelled() != nil {
+			break
+		}
+
+		delay := policy.delay(attempt)
+		e.logger.Infof("Step %s attempt %d of %d failed (%s): %v; retrying in %s",
+			step.Name, attempt, policy.MaxAttempts, ClassifyError(err), err, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, &StepFailure{Step: step.Name, Input: data, Err: fmt.Errorf("%w (gave up retrying: %v)", err, ctx.Err())}
+		case <-exec.stopped():
+			timer.Stop()
+			return nil, exec.cancelled()
+		case <-timer.C:
+		}
+	}
+
+	if errors.Is(err, ErrHalted) {
+		exec.recordHalt(step.Name, err, data)
+		return nil, err
+	}
+	if errors.Is(err, ErrCancelled) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &StepFailure{Step: step.Name, Input: data, Err: err}
+	}
+
+	exec.SetOutput(step.Name, result)
+	e.saveCheckpoint(ctx, exec, step, result)
+	return result, nil
+}
+
+// stepTimeout reads a step's "timeout", rendering it first when it is a template
+func stepTimeout(exec *Execution, step Step, data interface{}) (time.Duration, error) {
+	spec := step.Payload
+	if raw, ok := spec["timeout"].(string); ok && templated(raw) {
+		rendered, err := RenderString(raw, exec.Scope(data))
+		if err != nil {
+			return 0, fmt.Errorf("failed to render timeout: %w", err)
+		}
+		spec = map[string]interface{}{"timeout": rendered}
+	}
+	return durationOption(spec, "timeout", 0)
+}
+
+// attemptStep runs one attempt of a step within its timeout. The step's context is
+// cancelled, with the cancellation as its cause, when the run is cancelled. The attempt
+// is abandoned when the timeout or the run deadline passes or the run is cancelled,
+// even if the handler ignores its context.
+func (e *Engine) attemptStep(ctx context.Context, exec *Execution, step Step, data interface{}, timeout time.Duration) (interface{}, error) {
+	stepCtx, stopStep := context.WithCancelCause(ctx)
+	defer stopStep(nil)
+	if exec.stop != nil {
+		stop := exec.stop
+		unwatch := context.AfterFunc(stop, func() { stopStep(context.Cause(stop)) })
+		defer unwatch()
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		stepCtx, cancel = context.WithTimeout(stepCtx, timeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		result interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := e.executeStep(stepCtx, exec, step, data)
+		done <- outcome{result: result, err: err}
+	}()
+
+	var result outcome
+	select {
+	case result = <-done:
+	case <-stepCtx.Done():
+		result = outcome{err: context.Cause(stepCtx)}
+	}
+
+	if result.err != nil {
+		if cause := exec.cancelled(); cause != nil {
+			return nil, cause
+		}
+	}
+	if result.err != nil && errors.Is(stepCtx.Err(), context.DeadlineExceeded) {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: workflow exceeded its deadline", ErrTimedOut)
+		}
+		return nil, fmt.Errorf("%w: step exceeded its %s timeout", ErrTimedOut, timeout)
+	}
+
+	return result.result, result.err
+}
+
+// executeStep renders a step's payload templates, validates it and runs it through
+// its registered handler
+func (e *Engine) executeStep(ctx context.Context, exec *Execution, step Step, data interface{}) (interface{}, error) {
+	handler, err := LookupStep(step.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDryRun(exec, "step type", step.Type, handler); err != nil {
+		return nil, err
+	}
+
+	step.Payload, err = RenderPayload(step.Payload, exec.Scope(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render payload: %w", err)
+	}
+
+	if err := handler.Validate(step.Payload); err != nil {
+		return nil, fmt.Errorf("invalid step configuration: %w", err)
+	}
+
+	return handler.Execute(ctx, exec, step, data)
+}
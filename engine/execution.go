@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Execution is the state of a single workflow run handed to every step handler
+type Execution struct {
+	ID       int // workflow_executions row, 0 if the run could not be recorded
+	ParentID int // execution that called this workflow as a sub-workflow
+	ReplayOf int // failed execution this run replays
+	DB       DB
+	Logger   Logger
+	Workflow Workflow
+	Trigger  Trigger
+
+	engine  *Engine
+	callers []int // IDs of the workflows that led to this run, outermost first
+	mu      sync.RWMutex
+	outputs map[string]interface{}
+	vars    map[string]interface{}
+	halt    *haltInfo
+
+	restored map[string]interface{} // checkpointed step outputs of a resumed run
+	stop     context.Context        // cancelled when the run is asked to stop
+	trace    *dryRun                // set for dry runs
+}
+
+// haltInfo records where a workflow was halted by a step such as a filter
+type haltInfo struct {
+	step   string
+	reason string
+	data   interface{}
+}
+
+// RunSteps executes a sequence of steps within this execution, such as the steps of a
+// branch. Outputs are recorded by step name like top-level steps.
+func (e *Execution) RunSteps(ctx context.Context, sequence []Step, input interface{}) (interface{}, error) {
+	return e.engine.runSequence(ctx, e, sequence, input)
+}
+
+// child returns an execution for one iteration of a loop. It starts from the outputs
+// recorded so far but keeps its own, so concurrent iterations don't overwrite each
+// other, and adds vars such as the current item to the template scope.
+func (e *Execution) child(vars map[string]interface{}) *Execution {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	outputs := make(map[string]interface{}, len(e.outputs))
+	for name, output := range e.outputs {
+		outputs[name] = output
+	}
+	merged := make(map[string]interface{}, len(e.vars)+len(vars))
+	for name, value := range e.vars {
+		merged[name] = value
+	}
+	for name, value := range vars {
+		merged[name] = value
+	}
+
+	return &Execution{
+		ID:       e.ID,
+		ParentID: e.ParentID,
+		ReplayOf: e.ReplayOf,
+		DB:       e.DB,
+		Logger:   e.Logger,
+		Workflow: e.Workflow,
+		Trigger:  e.Trigger,
+		engine:   e.engine,
+		callers:  e.callers,
+		outputs:  outputs,
+		vars:     merged,
+		stop:     e.stop,
+		trace:    e.trace,
+	}
+}
+
+// topLevel reports whether a step is one of the workflow's own steps rather than one
+// nested in a branch or loop body
+func (e *Execution) topLevel(stepName string) bool {
+	for _, step := range e.Workflow.Steps {
+		if step.Name == stepName {
+			return true
+		}
+	}
+	return false
+}
+
+// recordHalt keeps the innermost step that halted the workflow
+func (e *Execution) recordHalt(stepName string, err error, data interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.halt == nil {
+		e.halt = &haltInfo{step: stepName, reason: err.Error(), data: data}
+	}
+}
+
+// SetOutput records the output of a step so later steps can address it by name
+func (e *Execution) SetOutput(stepName string, output interface{}) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.outputs == nil {
+		e.outputs = make(map[string]interface{})
+	}
+	e.outputs[stepName] = output
+}
+
+// Output returns the recorded output of a step
+func (e *Execution) Output(stepName string) (interface{}, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	output, ok := e.outputs[stepName]
+	return output, ok
+}
+
+// Scope is what {{ }} expressions in step payloads resolve against: the trigger, the
+// outputs of earlier steps by name, the step's own input, the workflow and the time.
+// Inside a loop the current item is available as loop.item and loop.index.
+func (e *Execution) Scope(input interface{}) map[string]interface{} {
+	e.mu.RLock()
+	outputs := make(map[string]interface{}, len(e.outputs))
+	for name, output := range e.outputs {
+		outputs[name] = output
+	}
+	vars := e.vars
+	e.mu.RUnlock()
+
+	files := make(map[string]interface{}, len(e.Trigger.Files))
+	for name, file := range e.Trigger.Files {
+		files[name] = map[string]interface{}{
+			"filename":    file.Filename,
+			"size":        file.Size,
+			"contentType": file.ContentType,
+		}
+	}
+	headers := make(map[string]interface{}, len(e.Trigger.Headers))
+	for name, value := range e.Trigger.Headers {
+		headers[name] = value
+	}
+
+	scope := map[string]interface{}{
+		"trigger": map[string]interface{}{
+			"type":        e.Trigger.Type,
+			"body":        e.Trigger.Body,
+			"headers":     headers,
+			"contentType": e.Trigger.ContentType,
+			"raw":         string(e.Trigger.Raw),
+			"files":       files,
+		},
+		"steps": outputs,
+		"input": input,
+		"workflow": map[string]interface{}{
+			"id":   e.Workflow.ID,
+			"name": e.Workflow.Name,
+		},
+		"now": time.Now(),
+	}
+	for name, value := range vars {
+		scope[name] = value
+	}
+
+	return scope
+}
@@ -0,0 +1,62 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+)
+
+// filterStep keeps the records matching its condition. A single object that does not
+// match halts the workflow instead.
+type filterStep struct{}
+
+func (filterStep) Validate(payload map[string]interface{}) error {
+	_, err := ParseCondition(payload)
+	return err
+}
+
+func (filterStep) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	condition, err := ParseCondition(step.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	records, ok := Records(input)
+	if !ok {
+		matched, err := condition.Evaluate(input)
+		if err != nil {
+			return nil, err
+		}
+		if !matched {
+			return nil, Halt("input did not match filter %s", step.Name)
+		}
+		return input, nil
+	}
+
+	kept := make([]interface{}, 0, len(records))
+	for i, record := range records {
+		matched, err := condition.Evaluate(record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i, err)
+		}
+		if matched {
+			kept = append(kept, record)
+		}
+	}
+
+	dropped := len(records) - len(kept)
+	exec.Logger.Infof("Filter %s kept %d records and dropped %d", step.Name, len(kept), dropped)
+	exec.ReportStats(ctx, map[string]interface{}{"kept": len(kept), "dropped": dropped})
+
+	return WithRecords(input, kept), nil
+}
+
+func (filterStep) Describe() Description {
+	return Description{
+		Summary: "Keeps records matching field/operator/value conditions combined with and/or groups; halts the workflow when a single object does not match",
+		Output:  `The input records that matched, in the same shape as the input; "kept" and "dropped" counts are reported in the step's stats`,
+	}
+}
+
+func (filterStep) SupportsDryRun() bool {
+	return true
+}
@@ -0,0 +1,188 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestConditionEvaluate(t *testing.T) {
+	record := map[string]interface{}{
+		"name":    "Ada Lovelace",
+		"age":     36.0,
+		"active":  true,
+		"tags":    []interface{}{"math", "poetry"},
+		"joined":  "2024-03-01T10:00:00Z",
+		"address": map[string]interface{}{"city": "London", "zip": "NW1"},
+		"note":    nil,
+	}
+
+	tests := []struct {
+		name string
+		spec map[string]interface{}
+		want bool
+	}{
+		{"eq on text", map[string]interface{}{"field": "name", "operator": "eq", "value": "Ada Lovelace"}, true},
+		{"eq compares numbers", map[string]interface{}{"field": "age", "operator": "eq", "value": "36"}, true},
+		{"eq on bool", map[string]interface{}{"field": "active", "operator": "eq", "value": true}, true},
+		{"eq on nested field", map[string]interface{}{"field": "address.city", "operator": "eq", "value": "London"}, true},
+		{"eq on list index", map[string]interface{}{"field": "tags.1", "operator": "eq", "value": "poetry"}, true},
+		{"eq on missing field", map[string]interface{}{"field": "address.country", "operator": "eq", "value": ""}, false},
+		{"neq", map[string]interface{}{"field": "address.city", "operator": "neq", "value": "Paris"}, true},
+		{"neq on missing field", map[string]interface{}{"field": "missing", "operator": "neq", "value": "x"}, true},
+		{"gt", map[string]interface{}{"field": "age", "operator": "gt", "value": 30.0}, true},
+		{"gte at the bound", map[string]interface{}{"field": "age", "operator": "gte", "value": 36.0}, true},
+		{"lt", map[string]interface{}{"field": "age", "operator": "lt", "value": 30.0}, false},
+		{"lte as text", map[string]interface{}{"field": "name", "operator": "lte", "value": "B"}, true},
+		{"contains in text", map[string]interface{}{"field": "name", "operator": "contains", "value": "Love"}, true},
+		{"contains in list", map[string]interface{}{"field": "tags", "operator": "contains", "value": "math"}, true},
+		{"contains not in list", map[string]interface{}{"field": "tags", "operator": "contains", "value": "Math"}, false},
+		{"in", map[string]interface{}{"field": "address.zip", "operator": "in", "value": []interface{}{"SW1", "NW1"}}, true},
+		{"regex", map[string]interface{}{"field": "name", "operator": "regex", "value": "^Ada\\s"}, true},
+		{"exists", map[string]interface{}{"field": "address.zip", "operator": "exists"}, true},
+		{"exists on null", map[string]interface{}{"field": "note", "operator": "exists"}, false},
+		{"exists false", map[string]interface{}{"field": "missing", "operator": "exists", "value": false}, true},
+		{"before", map[string]interface{}{"field": "joined", "operator": "before", "value": "2024-04-01"}, true},
+		{"after relative", map[string]interface{}{"field": "joined", "operator": "after", "value": "now-7d"}, false},
+		{"on", map[string]interface{}{"field": "joined", "operator": "on", "value": "2024-03-01"}, true},
+		{"date operator on a non-date", map[string]interface{}{"field": "name", "operator": "before", "value": "now"}, false},
+		{
+			name: "and group",
+			spec: map[string]interface{}{"and": []interface{}{
+				map[string]interface{}{"field": "active", "operator": "eq", "value": true},
+				map[string]interface{}{"field": "age", "operator": "gt", "value": 40.0},
+			}},
+			want: false,
+		},
+		{
+			name: "or group with a nested and",
+			spec: map[string]interface{}{"or": []interface{}{
+				map[string]interface{}{"field": "age", "operator": "gt", "value": 40.0},
+				map[string]interface{}{"and": []interface{}{
+					map[string]interface{}{"field": "address.city", "operator": "eq", "value": "London"},
+					map[string]interface{}{"field": "tags", "operator": "contains", "value": "poetry"},
+				}},
+			}},
+			want: true,
+		},
+		{
+			name: "nested condition key",
+			spec: map[string]interface{}{"condition": map[string]interface{}{"field": "age", "operator": "eq", "value": 36.0}},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition, err := ParseCondition(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseCondition: %v", err)
+			}
+			got, err := condition.Evaluate(record)
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConditionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		spec map[string]interface{}
+	}{
+		{"no field", map[string]interface{}{"operator": "eq", "value": 1.0}},
+		{"unknown operator", map[string]interface{}{"field": "a", "operator": "like", "value": "x"}},
+		{"empty and", map[string]interface{}{"and": []interface{}{}}},
+		{"or of non-conditions", map[string]interface{}{"or": []interface{}{"a"}}},
+		{"bad regex", map[string]interface{}{"field": "a", "operator": "regex", "value": "("}},
+		{"in without a list", map[string]interface{}{"field": "a", "operator": "in", "value": "x"}},
+		{"bad date", map[string]interface{}{"field": "a", "operator": "before", "value": "someday"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCondition(tt.spec); err == nil {
+				t.Error("ParseCondition accepted an invalid condition")
+			}
+		})
+	}
+}
+
+func TestParseConditionTemplatedValue(t *testing.T) {
+	spec := map[string]interface{}{"field": "a", "operator": "before", "value": "{{ trigger.body.since }}"}
+	if _, err := ParseCondition(spec); err != nil {
+		t.Errorf("templated value was checked before rendering: %v", err)
+	}
+}
+
+func TestFilterStep(t *testing.T) {
+	adult := map[string]interface{}{"name": "Ada", "age": 36.0}
+	child := map[string]interface{}{"name": "Tom", "age": 9.0}
+	payload := map[string]interface{}{"field": "age", "operator": "gte", "value": 18.0}
+
+	tests := []struct {
+		name      string
+		input     interface{}
+		want      interface{}
+		wantStats map[string]interface{}
+		wantHalt  bool
+	}{
+		{
+			name:      "array stays an array",
+			input:     []interface{}{adult, child},
+			want:      []interface{}{adult},
+			wantStats: map[string]interface{}{"kept": 1, "dropped": 1},
+		},
+		{
+			name:      "record set keeps its other fields",
+			input:     map[string]interface{}{"source": "csv", "records": []interface{}{child, adult, adult}},
+			want:      map[string]interface{}{"source": "csv", "records": []interface{}{adult, adult}},
+			wantStats: map[string]interface{}{"kept": 2, "dropped": 1},
+		},
+		{
+			name:      "empty array",
+			input:     []interface{}{},
+			want:      []interface{}{},
+			wantStats: map[string]interface{}{"kept": 0, "dropped": 0},
+		},
+		{
+			name:  "matching object passes through",
+			input: adult,
+			want:  adult,
+		},
+		{
+			name:     "object that does not match halts",
+			input:    child,
+			wantHalt: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec := &Execution{Logger: testLogger{t}}
+			ctx, stats := withStepStats(context.Background())
+
+			got, err := filterStep{}.Execute(ctx, exec, Step{Name: "adults", Type: "filter", Payload: payload}, tt.input)
+			if tt.wantHalt {
+				if !errors.Is(err, ErrHalted) {
+					t.Fatalf("Execute error = %v, want a halt", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("output = %#v\nwant %#v", got, tt.want)
+			}
+			if reported := stats.snapshot(); !reflect.DeepEqual(reported, tt.wantStats) {
+				t.Errorf("stats = %#v, want %#v", reported, tt.wantStats)
+			}
+		})
+	}
+}
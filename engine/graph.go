@@ -0,0 +1,233 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxParallel bounds how many steps of a graph run at the same time
+const maxParallel = 4
+
+// hasDependencies reports whether any step of a sequence declares dependencies, which
+// makes the sequence run as a graph
+func hasDependencies(sequence []Step) bool {
+	for _, step := range sequence {
+		if len(step.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkDependencies ensures every dependency names a sibling step and that the
+// dependencies form no cycle
+func checkDependencies(sequence []Step) error {
+	index := make(map[string]int, len(sequence))
+	for i, step := range sequence {
+		index[step.Name] = i
+	}
+
+	pending := make([]int, len(sequence))
+	dependents := make([][]int, len(sequence))
+	for i, step := range sequence {
+		for _, name := range uniqueNames(step.DependsOn) {
+			if name == step.Name {
+				return fmt.Errorf("step '%s' depends on itself", step.Name)
+			}
+			j, ok := index[name]
+			if !ok {
+				return fmt.Errorf("step '%s' depends on unknown step '%s'", step.Name, name)
+			}
+			pending[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	// Remove steps with no unmet dependencies until none are left; whatever remains
+	// waits on itself through a cycle
+	var ready []int
+	for i := range sequence {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		for _, j := range dependents[i] {
+			pending[j]--
+			if pending[j] == 0 {
+				ready = append(ready, j)
+			}
+		}
+	}
+
+	var cycle []string
+	for i, step := range sequence {
+		if pending[i] > 0 {
+			cycle = append(cycle, step.Name)
+		}
+	}
+	if len(cycle) > 0 {
+		return fmt.Errorf("dependency cycle involving steps %s", strings.Join(cycle, ", "))
+	}
+
+	return nil
+}
+
+// stepResult is what a graph worker reports back when a step finishes
+type stepResult struct {
+	index  int
+	output interface{}
+	err    error
+}
+
+// runGraph executes a sequence as a dependency graph. Steps without dependencies get
+// the sequence input; a step with one dependency gets its output, and a step with
+// several gets an object holding each dependency's output under its name. Ready steps
+// run in parallel, started in step order, up to maxParallel at a time.
+//
+// A halted step skips only the steps that depend on it. The result is the output of
+// the step nothing depends on, or an object keyed by name when there are several.
+func (e *Engine) runGraph(ctx context.Context, exec *Execution, sequence []Step, data interface{}) (interface{}, error) {
+	if err := checkDependencies(sequence); err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(sequence))
+	for i, step := range sequence {
+		index[step.Name] = i
+	}
+
+	pending := make([]int, len(sequence))
+	dependents := make([][]int, len(sequence))
+	for i, step := range sequence {
+		for _, name := range uniqueNames(step.DependsOn) {
+			pending[i]++
+			dependents[index[name]] = append(dependents[index[name]], i)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outputs := make([]interface{}, len(sequence))
+	done := make([]bool, len(sequence))
+	skipped := make([]bool, len(sequence))
+	results := make(chan stepResult)
+
+	var ready []int
+	for i := range sequence {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	var skip func(i int)
+	skip = func(i int) {
+		for _, j := range dependents[i] {
+			if !skipped[j] {
+				skipped[j] = true
+				skip(j)
+			}
+		}
+	}
+
+	running := 0
+	var failure, halted error
+	for {
+		for failure == nil && len(ready) > 0 && running < maxParallel {
+			i := ready[0]
+			ready = ready[1:]
+
+			input := data
+			if deps := uniqueNames(sequence[i].DependsOn); len(deps) == 1 {
+				input = outputs[index[deps[0]]]
+			} else if len(deps) > 1 {
+				merged := make(map[string]interface{}, len(deps))
+				for _, name := range deps {
+					merged[name] = outputs[index[name]]
+				}
+				input = merged
+			}
+
+			running++
+			go func(i int, input interface{}) {
+				output, err := e.runStep(ctx, exec, sequence[i], input)
+				results <- stepResult{index: i, output: output, err: err}
+			}(i, input)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+
+		switch {
+		case errors.Is(result.err, ErrHalted):
+			if halted == nil {
+				halted = result.err
+			}
+			skipped[result.index] = true
+			skip(result.index)
+		case result.err != nil:
+			if failure == nil {
+				failure = result.err
+				cancel()
+			}
+		default:
+			outputs[result.index] = result.output
+			done[result.index] = true
+			for _, j := range dependents[result.index] {
+				pending[j]--
+				if pending[j] == 0 && !skipped[j] {
+					ready = append(ready, j)
+				}
+			}
+			sort.Ints(ready)
+		}
+	}
+
+	if failure != nil {
+		return nil, failure
+	}
+
+	merged := make(map[string]interface{})
+	var last interface{}
+	for i, step := range sequence {
+		if len(dependents[i]) == 0 && done[i] {
+			merged[step.Name] = outputs[i]
+			last = outputs[i]
+		}
+	}
+
+	switch len(merged) {
+	case 0:
+		if halted != nil {
+			return nil, halted
+		}
+		return data, nil
+	case 1:
+		return last, nil
+	default:
+		return merged, nil
+	}
+}
+
+// uniqueNames drops repeated names, keeping the first occurrence
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	unique := make([]string, 0, len(names))
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterStep("test_label", labelStep{})
+}
+
+// labelStep wraps its input with its own name, so a graph's output shows which
+// steps fed which
+type labelStep struct{}
+
+func (labelStep) Validate(payload map[string]interface{}) error {
+	return nil
+}
+
+func (labelStep) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	return map[string]interface{}{"step": step.Name, "input": input}, nil
+}
+
+func (labelStep) Describe() Description {
+	return Description{Summary: "Test step labelling its input", Output: "The step name and its input"}
+}
+
+func (labelStep) SupportsDryRun() bool {
+	return true
+}
+
+func label(name string, input interface{}) map[string]interface{} {
+	return map[string]interface{}{"step": name, "input": input}
+}
+
+func labelled(name string, dependsOn ...string) Step {
+	return Step{Name: name, Type: "test_label", Payload: map[string]interface{}{}, DependsOn: dependsOn}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence []Step
+		wantErr  string
+	}{
+		{
+			name:     "diamond",
+			sequence: []Step{labelled("a"), labelled("b", "a"), labelled("c", "a"), labelled("d", "b", "c")},
+		},
+		{
+			name:     "dependency declared after its dependent",
+			sequence: []Step{labelled("b", "a"), labelled("a")},
+		},
+		{
+			name:     "repeated dependency",
+			sequence: []Step{labelled("a"), labelled("b", "a", "a")},
+		},
+		{
+			name:     "self dependency",
+			sequence: []Step{labelled("a", "a")},
+			wantErr:  "step 'a' depends on itself",
+		},
+		{
+			name:     "unknown step",
+			sequence: []Step{labelled("a"), labelled("b", "missing")},
+			wantErr:  "step 'b' depends on unknown step 'missing'",
+		},
+		{
+			name:     "two-step cycle",
+			sequence: []Step{labelled("a", "b"), labelled("b", "a")},
+			wantErr:  "dependency cycle involving steps a, b",
+		},
+		{
+			name:     "cycle downstream of a valid step",
+			sequence: []Step{labelled("root"), labelled("x", "root", "z"), labelled("y", "x"), labelled("z", "y"), labelled("leaf", "root")},
+			wantErr:  "dependency cycle involving steps x, y, z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDependencies(tt.sequence)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkDependencies: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("checkDependencies error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunGraph(t *testing.T) {
+	body := map[string]interface{}{"age": 9.0}
+	adultsOnly := Step{Name: "gate", Type: "filter", DependsOn: []string{"a"},
+		Payload: map[string]interface{}{"field": "input.age", "operator": "gte", "value": 18.0}}
+
+	tests := []struct {
+		name       string
+		steps      []Step
+		wantStatus string
+		want       interface{}
+		wantError  string
+		// wantOrder lists pairs of steps where the first must start before the second
+		wantOrder [][2]string
+		// wantSkipped lists steps that must not run
+		wantSkipped []string
+	}{
+		{
+			name:       "diamond merges outputs by name",
+			steps:      []Step{labelled("d", "b", "c"), labelled("c", "a"), labelled("b", "a"), labelled("a")},
+			wantStatus: "success",
+			want: label("d", map[string]interface{}{
+				"b": label("b", label("a", body)),
+				"c": label("c", label("a", body)),
+			}),
+			wantOrder: [][2]string{{"a", "b"}, {"a", "c"}, {"b", "d"}, {"c", "d"}},
+		},
+		{
+			name:       "several final steps",
+			steps:      []Step{labelled("a"), labelled("b", "a"), labelled("c", "a")},
+			wantStatus: "success",
+			want: map[string]interface{}{
+				"b": label("b", label("a", body)),
+				"c": label("c", label("a", body)),
+			},
+			wantOrder: [][2]string{{"a", "b"}, {"a", "c"}},
+		},
+		{
+			name:        "halt skips only dependents",
+			steps:       []Step{labelled("a"), adultsOnly, labelled("after", "gate"), labelled("other", "a")},
+			wantStatus:  "halted",
+			want:        label("other", label("a", body)),
+			wantSkipped: []string{"after"},
+		},
+		{
+			name:        "halt of every branch",
+			steps:       []Step{labelled("a"), adultsOnly, labelled("after", "gate")},
+			wantStatus:  "halted",
+			wantSkipped: []string{"after"},
+		},
+		{
+			name:        "cycle fails before any step runs",
+			steps:       []Step{labelled("a", "b"), labelled("b", "a")},
+			wantStatus:  "failed",
+			wantError:   "dependency cycle involving steps a, b",
+			wantSkipped: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workflow := Workflow{ID: 1, Steps: tt.steps}
+			trigger := Trigger{Type: "webhook", Body: body}
+
+			result := New(nil, testLogger{t}).DryRun(context.Background(), workflow, trigger)
+			if result.Status != tt.wantStatus {
+				t.Fatalf("dry run status = %s (%s), want %s", result.Status, result.Error, tt.wantStatus)
+			}
+			if tt.wantError != "" && !strings.Contains(result.Error, tt.wantError) {
+				t.Errorf("dry run error = %q, want %q", result.Error, tt.wantError)
+			}
+			if tt.want != nil && !reflect.DeepEqual(result.Result, tt.want) {
+				t.Errorf("result = %#v\nwant %#v", result.Result, tt.want)
+			}
+
+			started := make(map[string]int, len(result.Steps))
+			for i, trace := range result.Steps {
+				started[trace.Step] = i
+			}
+			for _, pair := range tt.wantOrder {
+				before, ok1 := started[pair[0]]
+				after, ok2 := started[pair[1]]
+				if !ok1 || !ok2 || before > after {
+					t.Errorf("step %s did not start before step %s", pair[0], pair[1])
+				}
+			}
+			for _, name := range tt.wantSkipped {
+				if _, ran := started[name]; ran {
+					t.Errorf("step %s ran, want it skipped", name)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package engine
+
+import "strings"
+
+// redactedValue replaces the value of a sensitive header in stored triggers
+const redactedValue = "[redacted]"
+
+// sensitiveHeaderParts mark header names that carry credentials, sessions or
+// signatures, such as Authorization, Cookie, X-Api-Key or X-Hub-Signature-256
+var sensitiveHeaderParts = []string{"authorization", "cookie", "token", "secret", "password", "signature", "api-key", "apikey", "session"}
+
+// redactHeaders returns a copy of a trigger's headers with the values of sensitive
+// headers replaced, for triggers that are stored and shown again later
+func redactHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		lower := strings.ToLower(name)
+		for _, part := range sensitiveHeaderParts {
+			if strings.Contains(lower, part) {
+				value = redactedValue
+				break
+			}
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
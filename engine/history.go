@@ -0,0 +1,269 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ExecutionRecord is a run as recorded in workflow_executions
+type ExecutionRecord struct {
+	ID                  int         `json:"id"`
+	WorkflowID          int         `json:"workflow_id"`
+	Status              string      `json:"status"` // queued, running, success, halted, failed, timed_out, cancelled
+	Message             *string     `json:"message"`
+	TriggerType         *string     `json:"trigger_type"`
+	Input               interface{} `json:"input_data"`
+	Output              interface{} `json:"output_data"`
+	ExecutedAt          time.Time   `json:"executed_at"`
+	DurationMs          *int        `json:"duration_ms"`
+	ParentExecutionID   *int        `json:"parent_execution_id"`
+	ReplayOfExecutionID *int        `json:"replay_of_execution_id"`
+	FailedStep          *string     `json:"failed_step"`
+	CancelledBy         *string     `json:"cancelled_by"`
+	CancelledAt         *time.Time  `json:"cancelled_at"`
+}
+
+// LoadExecution fetches a recorded run
+func LoadExecution(ctx context.Context, db DB, id int) (*ExecutionRecord, error) {
+	query := `SELECT id, workflow_id, status, message, trigger_type, input_data, output_data, executed_at, duration_ms,
+		parent_execution_id, replay_of_execution_id, failed_step, cancelled_by, cancelled_at
+		FROM workflow_executions WHERE id = $1`
+
+	var record ExecutionRecord
+	var input, output []byte
+	err := db.QueryRowContext(ctx, query, id).Scan(&record.ID, &record.WorkflowID, &record.Status, &record.Message,
+		&record.TriggerType, &input, &output, &record.ExecutedAt, &record.DurationMs, &record.ParentExecutionID,
+		&record.ReplayOfExecutionID, &record.FailedStep, &record.CancelledBy, &record.CancelledAt)
+	if err != nil {
+		return nil, fmt.Errorf("execution not found: %w", err)
+	}
+
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &record.Input); err != nil {
+			return nil, fmt.Errorf("invalid input data for execution %d: %w", id, err)
+		}
+	}
+	if len(output) > 0 {
+		if err := json.Unmarshal(output, &record.Output); err != nil {
+			return nil, fmt.Errorf("invalid output data for execution %d: %w", id, err)
+		}
+	}
+
+	return &record, nil
+}
+
+// startExecution records a run in workflow_executions with the given status and
+// returns its ID. A run that cannot be recorded still goes ahead, so failures are
+// only logged.
+func (e *Engine) startExecution(ctx context.Context, exec *Execution, status string) int {
+	query := `INSERT INTO workflow_executions (workflow_id, status, executed_at, parent_execution_id, replay_of_execution_id,
+		trigger_type, input_data)
+		VALUES ($1, $2, NOW(), $3, $4, $5, $6) RETURNING id`
+
+	var id int
+	err := e.db.QueryRowContext(ctx, query, exec.Workflow.ID, status, nullableID(exec.ParentID), nullableID(exec.ReplayOf),
+		exec.Trigger.Type, jsonColumn(exec.Trigger.Body)).Scan(&id)
+	if err != nil {
+		e.logger.Errorf("Failed to record execution of workflow %d: %v", exec.Workflow.ID, err)
+		return 0
+	}
+
+	return id
+}
+
+// markRunning moves a queued run to running. It reports false if the run was
+// cancelled before it could start.
+func (e *Engine) markRunning(ctx context.Context, executionID int) bool {
+	result, err := e.db.ExecContext(ctx, `UPDATE workflow_executions SET status = 'running' WHERE id = $1 AND status <> 'cancelled'`, executionID)
+	if err != nil {
+		e.logger.Errorf("Failed to update execution %d: %v", executionID, err)
+		return true
+	}
+	n, err := result.RowsAffected()
+	return err != nil || n > 0
+}
+
+// finishExecution stores the outcome of a recorded run
+func (e *Engine) finishExecution(ctx context.Context, executionID int, status, message string, output interface{}, started time.Time) {
+	if executionID == 0 {
+		return
+	}
+
+	// Record the outcome even when the run ran out of time or its caller went away
+	ctx = context.WithoutCancel(ctx)
+
+	query := `UPDATE workflow_executions SET status = $1, message = $2, output_data = $3, duration_ms = $4
+		WHERE id = $5`
+
+	_, err := e.db.ExecContext(ctx, query, status, message, jsonColumn(output), time.Since(started).Milliseconds(), executionID)
+	if err != nil {
+		e.logger.Errorf("Failed to update execution %d: %v", executionID, err)
+	}
+}
+
+// stepStats collects the figures a step attempt reports about its work, such as how
+// many records a filter kept
+type stepStats struct {
+	mu     sync.Mutex
+	values map[string]interface{}
+}
+
+type stepStatsKey struct{}
+
+// withStepStats returns a context for one step attempt that ReportStats can add to
+func withStepStats(ctx context.Context) (context.Context, *stepStats) {
+	stats := &stepStats{}
+	return context.WithValue(ctx, stepStatsKey{}, stats), stats
+}
+
+// ReportStats adds figures about the work of the step running in ctx to its recorded
+// attempt and to its dry run trace, without changing the step's output
+func (e *Execution) ReportStats(ctx context.Context, values map[string]interface{}) {
+	stats, ok := ctx.Value(stepStatsKey{}).(*stepStats)
+	if !ok {
+		return
+	}
+
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if stats.values == nil {
+		stats.values = make(map[string]interface{}, len(values))
+	}
+	for name, value := range values {
+		stats.values[name] = value
+	}
+}
+
+// snapshot returns the reported figures, or nil when there are none
+func (s *stepStats) snapshot() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.values) == 0 {
+		return nil
+	}
+	values := make(map[string]interface{}, len(s.values))
+	for name, value := range s.values {
+		values[name] = value
+	}
+	return values
+}
+
+// recordAttempt records one attempt at running a step of a recorded run with the
+// input it was given, the output it produced and the stats it reported
+func (e *Engine) recordAttempt(ctx context.Context, exec *Execution, step Step, attempt int, input, output interface{}, stats map[string]interface{}, stepErr error, started time.Time) {
+	if exec.ID == 0 {
+		return
+	}
+
+	status, message := "success", ""
+	switch {
+	case errors.Is(stepErr, ErrHalted):
+		status, message = "halted", stepErr.Error()
+	case stepErr != nil:
+		status, message = "failed", stepErr.Error()
+	}
+
+	// Record the attempt even when it ran out of time
+	ctx = context.WithoutCancel(ctx)
+
+	query := `INSERT INTO step_executions (execution_id, step_id, step_name, attempt, status, error_message, error_class,
+		input_data, output_data, stats, started_at, completed_at, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NOW(), $12)`
+
+	var errorClass *string
+	if status == "failed" {
+		class := ClassifyError(stepErr)
+		errorClass = &class
+	}
+
+	_, err := e.db.ExecContext(ctx, query, exec.ID, step.ID, step.Name, attempt, status, message, errorClass,
+		jsonColumn(input), jsonColumn(output), jsonColumn(stats), started, time.Since(started).Milliseconds())
+	if err != nil {
+		e.logger.Errorf("Failed to record attempt %d of step %s: %v", attempt, step.Name, err)
+	}
+}
+
+// StepExecution is one attempt at running a step as recorded in step_executions
+type StepExecution struct {
+	ID           int         `json:"id"`
+	ExecutionID  int         `json:"execution_id"`
+	StepID       *int        `json:"step_id"`
+	StepName     string      `json:"step_name"`
+	Attempt      int         `json:"attempt"`
+	Status       string      `json:"status"` // success, failed, halted, restored
+	ErrorMessage *string     `json:"error_message"`
+	ErrorClass   *string     `json:"error_class"`
+	Input        interface{} `json:"input_data"`
+	Output       interface{} `json:"output_data"`
+	Stats        interface{} `json:"stats"`
+	StartedAt    time.Time   `json:"started_at"`
+	CompletedAt  *time.Time  `json:"completed_at"`
+	DurationMs   *int        `json:"duration_ms"`
+}
+
+// ListStepExecutions returns every recorded step attempt of a run in the order they started
+func ListStepExecutions(ctx context.Context, db DB, executionID int) ([]StepExecution, error) {
+	query := `SELECT id, execution_id, step_id, step_name, attempt, status, error_message, error_class, input_data,
+		output_data, stats, started_at, completed_at, duration_ms
+		FROM step_executions WHERE execution_id = $1 ORDER BY started_at, id`
+	rows, err := db.QueryContext(ctx, query, executionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch step executions: %w", err)
+	}
+	defer rows.Close()
+
+	steps := []StepExecution{}
+	for rows.Next() {
+		var step StepExecution
+		var input, output, stats []byte
+		err := rows.Scan(&step.ID, &step.ExecutionID, &step.StepID, &step.StepName, &step.Attempt, &step.Status,
+			&step.ErrorMessage, &step.ErrorClass, &input, &output, &stats, &step.StartedAt, &step.CompletedAt, &step.DurationMs)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(input) > 0 {
+			if err := json.Unmarshal(input, &step.Input); err != nil {
+				return nil, fmt.Errorf("invalid input data for step %s: %w", step.StepName, err)
+			}
+		}
+		if len(output) > 0 {
+			if err := json.Unmarshal(output, &step.Output); err != nil {
+				return nil, fmt.Errorf("invalid output data for step %s: %w", step.StepName, err)
+			}
+		}
+		if len(stats) > 0 {
+			if err := json.Unmarshal(stats, &step.Stats); err != nil {
+				return nil, fmt.Errorf("invalid stats for step %s: %w", step.StepName, err)
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, rows.Err()
+}
+
+// nullableID stores a missing ID as NULL
+func nullableID(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// jsonColumn encodes a value for a JSONB column, storing NULL for nil or values that
+// cannot be encoded
+func jsonColumn(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return string(encoded)
+}
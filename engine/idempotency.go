@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxIdempotencyKeyLength bounds the keys remembered for webhook deliveries
+const maxIdempotencyKeyLength = 255
+
+// DeliveryKey returns the idempotency key of a webhook delivery: the Idempotency-Key
+// header, or the value at the workflow's idempotencyKey path in the body. It returns
+// "" when the delivery has neither.
+func DeliveryKey(settings Settings, trigger Trigger) (string, error) {
+	for name, value := range trigger.Headers {
+		if strings.EqualFold(name, "Idempotency-Key") && strings.TrimSpace(value) != "" {
+			return checkDeliveryKey(strings.TrimSpace(value))
+		}
+	}
+
+	if settings.IdempotencyKey == "" {
+		return "", nil
+	}
+	value, ok := LookupPath(trigger.Body, settings.IdempotencyKey)
+	if !ok || value == nil {
+		return "", nil
+	}
+	return checkDeliveryKey(textValue(value))
+}
+
+func checkDeliveryKey(key string) (string, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return "", fmt.Errorf("idempotency key is longer than %d characters", maxIdempotencyKeyLength)
+	}
+	return key, nil
+}
+
+// ClaimDeliveryKey remembers a webhook delivery by its key for the retention window.
+// It returns true when the delivery is new, or when the earlier one has been
+// forgotten. For a duplicate it returns false and the execution of the original
+// delivery, which is 0 while that delivery is still being queued.
+func ClaimDeliveryKey(ctx context.Context, db DB, workflowID int, key string, window time.Duration) (bool, int, error) {
+	query := `INSERT INTO idempotency_keys (workflow_id, idempotency_key) VALUES ($1, $2)
+		ON CONFLICT (workflow_id, idempotency_key) DO UPDATE SET execution_id = NULL, created_at = NOW()
+		WHERE idempotency_keys.created_at < NOW() - make_interval(secs => $3)
+		RETURNING workflow_id`
+	var claimedWorkflow int
+	err := db.QueryRowContext(ctx, query, workflowID, key, window.Seconds()).Scan(&claimedWorkflow)
+	if err == nil {
+		return true, 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, 0, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+
+	var executionID sql.NullInt64
+	query = `SELECT execution_id FROM idempotency_keys WHERE workflow_id = $1 AND idempotency_key = $2`
+	if err := db.QueryRowContext(ctx, query, workflowID, key).Scan(&executionID); err != nil {
+		return false, 0, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+	return false, int(executionID.Int64), nil
+}
+
+// LinkDeliveryKey records the execution started for a delivery
+func LinkDeliveryKey(ctx context.Context, db DB, workflowID int, key string, executionID int) error {
+	query := `UPDATE idempotency_keys SET execution_id = $1 WHERE workflow_id = $2 AND idempotency_key = $3`
+	if _, err := db.ExecContext(ctx, query, executionID, workflowID, key); err != nil {
+		return fmt.Errorf("failed to record idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseDeliveryKey forgets a delivery that did not start a run, so the sender's
+// retry is accepted
+func ReleaseDeliveryKey(ctx context.Context, db DB, workflowID int, key string) error {
+	query := `DELETE FROM idempotency_keys WHERE workflow_id = $1 AND idempotency_key = $2 AND execution_id IS NULL`
+	if _, err := db.ExecContext(context.WithoutCancel(ctx), query, workflowID, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxLoopConcurrency bounds how many items of a loop run at the same time
+const maxLoopConcurrency = 20
+
+// loopStep runs the steps of its "body" branch once for every element of an array.
+// The array is the "items" value, usually a template such as
+// {{ steps.parse.records }}, the value at "field" in the input, or the input's records.
+//
+// onError decides what a failing item does: "fail" stops the loop and fails the step,
+// "continue" records the error and moves on, and "collect" runs every item and then
+// fails the step with all the errors together.
+type loopStep struct{}
+
+var loopErrorModes = map[string]bool{"fail": true, "continue": true, "collect": true}
+
+type loopOptions struct {
+	concurrency int
+	onError     string
+}
+
+func (loopStep) Validate(payload map[string]interface{}) error {
+	_, err := loopOptionsFromPayload(payload)
+	return err
+}
+
+func (loopStep) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	opts, err := loopOptionsFromPayload(step.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := loopItems(step.Payload, input)
+	if err != nil {
+		return nil, err
+	}
+
+	body := step.Branches["body"]
+	if len(body) == 0 {
+		return nil, fmt.Errorf("loop requires steps in its body branch")
+	}
+
+	exec.Logger.Infof("Loop %s iterating over %d items (concurrency %d, onError %s)", step.Name, len(items), opts.concurrency, opts.onError)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]map[string]interface{}, len(items))
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failure  error
+		failures []string
+	)
+	slots := make(chan struct{}, opts.concurrency)
+
+	for i, item := range items {
+		slots <- struct{}{}
+		mu.Lock()
+		stop := failure != nil || exec.cancelled() != nil
+		mu.Unlock()
+		if stop {
+			<-slots
+			break
+		}
+
+		wg.Add(1)
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			iteration := exec.child(map[string]interface{}{
+				"loop": map[string]interface{}{"index": i, "item": item, "count": len(items)},
+			})
+			output, err := iteration.RunSteps(ctx, body, item)
+
+			result := map[string]interface{}{"index": i, "item": item}
+			switch {
+			case errors.Is(err, ErrHalted):
+				result["status"] = "skipped"
+				result["reason"] = err.Error()
+			case err != nil:
+				result["status"] = "failed"
+				result["error"] = err.Error()
+			default:
+				result["status"] = "succeeded"
+				result["output"] = output
+			}
+			results[i] = result
+
+			if err != nil && !errors.Is(err, ErrHalted) {
+				mu.Lock()
+				defer mu.Unlock()
+				failures = append(failures, fmt.Sprintf("item %d: %v", i, err))
+				if opts.onError == "fail" && failure == nil {
+					failure = fmt.Errorf("item %d: %w", i, err)
+					cancel()
+				}
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	if err := exec.cancelled(); err != nil {
+		return nil, err
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	if opts.onError == "collect" && len(failures) > 0 {
+		return nil, fmt.Errorf("%d of %d items failed: %s", len(failures), len(items), strings.Join(failures, "; "))
+	}
+
+	output := make([]interface{}, 0, len(items))
+	counts := map[string]int{"succeeded": 0, "failed": 0, "skipped": 0}
+	for _, result := range results {
+		output = append(output, result)
+		counts[result["status"].(string)]++
+	}
+
+	return map[string]interface{}{
+		"results":   output,
+		"count":     len(items),
+		"succeeded": counts["succeeded"],
+		"failed":    counts["failed"],
+		"skipped":   counts["skipped"],
+	}, nil
+}
+
+func (loopStep) Describe() Description {
+	return Description{
+		Summary: `Runs the steps of the "body" branch for each element of "items", "field" or the input's records, "concurrency" at a time, with "onError" fail, continue or collect`,
+		Output:  `{"results": [{"index", "item", "status", "output" or "error"}], "count", "succeeded", "failed", "skipped"}`,
+	}
+}
+
+func (loopStep) SupportsDryRun() bool {
+	return true
+}
+
+// loopOptionsFromPayload reads concurrency and error handling from a loop payload
+func loopOptionsFromPayload(payload map[string]interface{}) (loopOptions, error) {
+	opts := loopOptions{concurrency: 1, onError: "fail"}
+
+	if raw, ok := payload["concurrency"]; ok && !templated(raw) {
+		concurrency, ok := raw.(float64)
+		if !ok || concurrency < 1 || concurrency != float64(int(concurrency)) {
+			return opts, fmt.Errorf("concurrency must be a whole number of at least 1")
+		}
+		if concurrency > maxLoopConcurrency {
+			return opts, fmt.Errorf("concurrency cannot exceed %d", maxLoopConcurrency)
+		}
+		opts.concurrency = int(concurrency)
+	}
+
+	if onError, ok := payload["onError"].(string); ok && onError != "" {
+		if !loopErrorModes[onError] {
+			return opts, fmt.Errorf("onError must be fail, continue or collect")
+		}
+		opts.onError = onError
+	}
+
+	return opts, nil
+}
+
+// loopItems finds the array a loop iterates over
+func loopItems(payload map[string]interface{}, input interface{}) ([]interface{}, error) {
+	if raw, ok := payload["items"]; ok {
+		if items, ok := Records(raw); ok {
+			return items, nil
+		}
+		return nil, fmt.Errorf("items must be an array")
+	}
+
+	if field, _ := payload["field"].(string); field != "" {
+		value, found := LookupPath(input, field)
+		if !found {
+			return nil, fmt.Errorf("field %s not found in input", field)
+		}
+		items, ok := Records(value)
+		if !ok {
+			return nil, fmt.Errorf("field %s is not an array", field)
+		}
+		return items, nil
+	}
+
+	items, ok := Records(input)
+	if !ok {
+		return nil, fmt.Errorf("loop input has no records; set items or field")
+	}
+	return items, nil
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// errorHandlerTimeout bounds the error handler steps and error workflow of a failed
+// run. They run after the run's own deadline, and after a webhook caller went away.
+const errorHandlerTimeout = 2 * time.Minute
+
+// handleFailure runs a failed run's error path: its onError steps, then the error
+// workflow named in its settings. Both receive the failed step, the error, the input
+// the step was given and the execution ID. Failures of the error path are only logged.
+func (e *Engine) handleFailure(ctx context.Context, exec *Execution, runErr error) {
+	errorWorkflowID := exec.Workflow.Settings.ErrorWorkflowID
+	// Error workflows don't trigger error workflows, so a failing one can't loop
+	if exec.Trigger.Type == "error" || errorWorkflowID == exec.Workflow.ID {
+		errorWorkflowID = 0
+	}
+	if len(exec.Workflow.OnError) == 0 && errorWorkflowID == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), errorHandlerTimeout)
+	defer cancel()
+
+	failure := map[string]interface{}{
+		"workflowId":  exec.Workflow.ID,
+		"executionId": exec.ID,
+		"error":       runErr.Error(),
+		"errorClass":  ClassifyError(runErr),
+		"trigger":     exec.Trigger.Body,
+	}
+	if failed, ok := FailedStep(runErr); ok {
+		failure["failedStep"] = failed.Step
+		failure["error"] = failed.Err.Error()
+		failure["input"] = failed.Input
+	}
+
+	if len(exec.Workflow.OnError) > 0 {
+		e.logger.Infof("Running error handler steps of workflow %d for execution %d", exec.Workflow.ID, exec.ID)
+		if _, err := exec.RunSteps(ctx, exec.Workflow.OnError, failure); err != nil {
+			e.logger.Errorf("Error handler steps of workflow %d failed: %v", exec.Workflow.ID, err)
+		}
+	}
+
+	if errorWorkflowID != 0 {
+		workflow, err := LoadWorkflow(ctx, e.db, errorWorkflowID)
+		if err != nil || workflow.UserID != exec.Workflow.UserID {
+			e.logger.Errorf("Error workflow %d of workflow %d not found", errorWorkflowID, exec.Workflow.ID)
+			return
+		}
+
+		e.logger.Infof("Running error workflow %d for execution %d", errorWorkflowID, exec.ID)
+		child := &Execution{
+			ParentID: exec.ID,
+			DB:       e.db,
+			Logger:   e.logger,
+			Workflow: *workflow,
+			Trigger:  Trigger{Type: "error", Body: failure},
+			engine:   e,
+			callers:  append(append([]int{}, exec.callers...), exec.Workflow.ID),
+			trace:    exec.trace,
+		}
+		if _, err := e.run(ctx, child); err != nil {
+			e.logger.Errorf("Error workflow %d failed: %v", errorWorkflowID, err)
+		}
+	}
+}
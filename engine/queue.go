@@ -0,0 +1,433 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// jobLease is how long a claimed job stays with its worker without a heartbeat
+	jobLease = 30 * time.Second
+	// maxJobAttempts bounds how often a job is retried after its worker disappeared
+	maxJobAttempts = 3
+	// jobFileRetention is how long the uploaded files of a failed run are kept for it
+	// to be resumed or replayed
+	jobFileRetention = 7 * 24 * time.Hour
+)
+
+// ErrLeaseLost is returned for jobs whose lease expired while they ran, so another
+// worker claimed them again
+var ErrLeaseLost = errors.New("job lease lost")
+
+// workerID identifies this process as the owner of the jobs it claims
+var workerID = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}()
+
+// Kinds of job
+const (
+	jobRun    = "run"    // a new run of a workflow
+	jobResume = "resume" // a failed run resumed from the step it failed at
+)
+
+// Job is a queued run of a workflow
+type Job struct {
+	ID          int
+	ExecutionID int
+	WorkflowID  int
+	Trigger     Trigger
+	Attempts    int
+	Kind        string
+	StepConfig  map[string]interface{} // step payloads a resumed run uses instead of the saved ones
+}
+
+// Queue is the durable job table that webhook and scheduled runs go through. Workers
+// claim jobs with SELECT ... FOR UPDATE SKIP LOCKED and keep a lease on them with
+// heartbeats; jobs whose lease expires, because their worker died, are queued again.
+// A job can therefore run more than once, but is never lost.
+type Queue struct {
+	engine *Engine
+}
+
+// NewQueue creates a queue whose jobs run on the given engine
+func NewQueue(e *Engine) *Queue {
+	return &Queue{engine: e}
+}
+
+// Enqueue records a queued run of a workflow and stores its trigger, including the
+// content of uploaded files, as a job. It returns the job with its execution ID.
+// Runs the workflow's concurrency settings turn away fail with ErrConcurrencyLimit
+// or ErrSkipped.
+func (q *Queue) Enqueue(ctx context.Context, workflow Workflow, trigger Trigger) (*Job, error) {
+	if err := q.admit(ctx, workflow); err != nil {
+		return nil, err
+	}
+
+	exec := q.engine.Start(ctx, workflow, trigger)
+	return q.enqueue(ctx, exec, func(jobID int) error {
+		return q.storeFiles(ctx, jobID, trigger.Files)
+	})
+}
+
+// Replay queues a dead letter's trigger as a new run of the workflow as it is now,
+// with the files uploaded to the failed run. The run is linked to the failed one and
+// the dead letter is marked replayed; a replay that fails again is dead-lettered
+// itself. Replays are subject to the workflow's concurrency settings like any run.
+func (q *Queue) Replay(ctx context.Context, workflow Workflow, letter DeadLetter) (*Job, error) {
+	if err := q.admit(ctx, workflow); err != nil {
+		return nil, err
+	}
+
+	exec := &Execution{
+		DB:       q.engine.db,
+		Logger:   q.engine.logger,
+		Workflow: workflow,
+		Trigger:  letter.Trigger,
+		engine:   q.engine,
+		ReplayOf: letter.ExecutionID,
+	}
+	exec.ID = q.engine.startExecution(ctx, exec, "queued")
+
+	job, err := q.enqueue(ctx, exec, func(jobID int) error {
+		return q.copyFiles(ctx, jobID, letter.ExecutionID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE dead_letters SET status = 'replayed', replayed_at = NOW(), replay_execution_id = $1 WHERE id = $2`
+	if _, err := q.engine.db.ExecContext(ctx, query, job.ExecutionID, letter.ID); err != nil {
+		q.engine.logger.Errorf("Failed to mark dead letter %d replayed: %v", letter.ID, err)
+	}
+	return job, nil
+}
+
+// enqueue stores the job of a recorded run. storeFiles saves the run's uploaded files
+// before workers can see the job.
+func (q *Queue) enqueue(ctx context.Context, exec *Execution, storeFiles func(jobID int) error) (*Job, error) {
+	if exec.ID == 0 {
+		return nil, fmt.Errorf("failed to record execution of workflow %d", exec.Workflow.ID)
+	}
+
+	stored := exec.Trigger
+	stored.Files = nil
+	triggerJSON, err := json.Marshal(stored)
+	if err != nil {
+		return nil, q.abandon(ctx, exec.ID, fmt.Errorf("failed to encode trigger: %w", err))
+	}
+
+	job := &Job{ExecutionID: exec.ID, WorkflowID: exec.Workflow.ID, Trigger: exec.Trigger, Kind: jobRun}
+	query := `INSERT INTO jobs (execution_id, workflow_id, trigger_data, max_attempts) VALUES ($1, $2, $3, $4) RETURNING id`
+	err = q.engine.db.QueryRowContext(ctx, query, exec.ID, exec.Workflow.ID, string(triggerJSON), maxJobAttempts).Scan(&job.ID)
+	if err != nil {
+		return nil, q.abandon(ctx, exec.ID, fmt.Errorf("failed to enqueue run: %w", err))
+	}
+
+	if err := storeFiles(job.ID); err != nil {
+		return nil, q.abandon(ctx, exec.ID, err)
+	}
+
+	// Workers only see the job once its files are stored
+	_, err = q.engine.db.ExecContext(ctx, `UPDATE jobs SET status = 'queued' WHERE id = $1`, job.ID)
+	if err != nil {
+		return nil, q.abandon(ctx, exec.ID, fmt.Errorf("failed to enqueue run: %w", err))
+	}
+
+	return job, nil
+}
+
+// storeFiles saves the content of a run's uploaded files with its job
+func (q *Queue) storeFiles(ctx context.Context, jobID int, files map[string]File) error {
+	for field, file := range files {
+		content, err := os.ReadFile(file.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read uploaded file %s: %w", file.Filename, err)
+		}
+		query := `INSERT INTO job_files (job_id, field, filename, content_type, content) VALUES ($1, $2, $3, $4, $5)`
+		_, err = q.engine.db.ExecContext(ctx, query, jobID, field, file.Filename, file.ContentType, content)
+		if err != nil {
+			return fmt.Errorf("failed to store uploaded file %s: %w", file.Filename, err)
+		}
+	}
+	return nil
+}
+
+// copyFiles gives a job the uploaded files of an earlier run
+func (q *Queue) copyFiles(ctx context.Context, jobID, executionID int) error {
+	query := `INSERT INTO job_files (job_id, field, filename, content_type, content)
+		SELECT $1, field, filename, content_type, content FROM job_files
+		WHERE job_id = (SELECT id FROM jobs WHERE execution_id = $2 ORDER BY id LIMIT 1)`
+	if _, err := q.engine.db.ExecContext(ctx, query, jobID, executionID); err != nil {
+		return fmt.Errorf("failed to copy uploaded files of execution %d: %w", executionID, err)
+	}
+	return nil
+}
+
+// abandon marks the execution of a run that could not be enqueued as failed
+func (q *Queue) abandon(ctx context.Context, executionID int, err error) error {
+	q.engine.finishExecution(ctx, executionID, "failed", err.Error(), nil, time.Now())
+	_, _ = q.engine.db.ExecContext(context.WithoutCancel(ctx), `DELETE FROM jobs WHERE execution_id = $1`, executionID)
+	return err
+}
+
+const claimReturning = `RETURNING id, execution_id, workflow_id, trigger_data, attempts, kind, step_config`
+
+// Claim takes the oldest queued job whose workflow and user are below their
+// concurrency limits and leases it to this process. It returns nil when no job can run.
+func (q *Queue) Claim(ctx context.Context) (*Job, error) {
+	query := `UPDATE jobs SET status = 'running', lease_owner = $1, lease_expires_at = NOW() + make_interval(secs => $2),
+		attempts = attempts + 1, updated_at = NOW()
+		WHERE id = (
+			SELECT j.id FROM jobs j JOIN workflows w ON w.id = j.workflow_id
+			WHERE j.status = 'queued' AND ` + belowConcurrencyLimits + `
+			ORDER BY j.id FOR UPDATE OF j SKIP LOCKED LIMIT 1
+		) ` + claimReturning
+	return q.claim(ctx, query, workerID, jobLease.Seconds())
+}
+
+// ClaimJob leases a specific queued job to this process, so a webhook can run the job
+// it just enqueued while keeping it durable
+func (q *Queue) ClaimJob(ctx context.Context, jobID int) (*Job, error) {
+	query := `UPDATE jobs SET status = 'running', lease_owner = $1, lease_expires_at = NOW() + make_interval(secs => $2),
+		attempts = attempts + 1, updated_at = NOW()
+		WHERE id = (
+			SELECT j.id FROM jobs j JOIN workflows w ON w.id = j.workflow_id
+			WHERE j.id = $3 AND j.status = 'queued' AND ` + belowConcurrencyLimits + `
+			FOR UPDATE OF j SKIP LOCKED
+		) ` + claimReturning
+	job, err := q.claim(ctx, query, workerID, jobLease.Seconds(), jobID)
+	if err == nil && job == nil {
+		return nil, fmt.Errorf("job %d was claimed by another worker or is waiting for a concurrency limit", jobID)
+	}
+	return job, err
+}
+
+func (q *Queue) claim(ctx context.Context, query string, args ...interface{}) (*Job, error) {
+	var job Job
+	var triggerJSON, configJSON []byte
+	err := q.engine.db.QueryRowContext(ctx, query, args...).Scan(&job.ID, &job.ExecutionID, &job.WorkflowID, &triggerJSON,
+		&job.Attempts, &job.Kind, &configJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	if err := json.Unmarshal(triggerJSON, &job.Trigger); err != nil {
+		return nil, fmt.Errorf("invalid trigger for job %d: %w", job.ID, err)
+	}
+	if len(configJSON) > 0 {
+		if err := json.Unmarshal(configJSON, &job.StepConfig); err != nil {
+			return nil, fmt.Errorf("invalid step config for job %d: %w", job.ID, err)
+		}
+	}
+	return &job, nil
+}
+
+// Process runs a claimed job against the current definition of its workflow, keeping
+// its lease alive until the run is over. The uploaded files of runs that succeed or
+// are cancelled are dropped; those of failed runs are kept for jobFileRetention so
+// the run can be resumed or replayed. It returns ErrLeaseLost when the job was claimed
+// again by another worker in the meantime.
+func (q *Queue) Process(ctx context.Context, job *Job) (interface{}, error) {
+	stop := q.heartbeat(ctx, job)
+	defer stop()
+
+	result, err := q.process(ctx, job)
+
+	// Only the lease holder completes the job. A worker whose lease expired leaves it to
+	// the one that claimed it again, along with its uploaded files.
+	completed, completeErr := q.engine.db.ExecContext(context.WithoutCancel(ctx),
+		`UPDATE jobs SET status = 'done', lease_owner = NULL, lease_expires_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'running' AND lease_owner = $2 AND attempts = $3`, job.ID, workerID, job.Attempts)
+	if completeErr != nil {
+		q.engine.logger.Errorf("Failed to complete job %d: %v", job.ID, completeErr)
+	} else if rows, rowsErr := completed.RowsAffected(); rowsErr == nil && rows == 0 {
+		q.engine.logger.Errorf("Job %d lost its lease during attempt %d and was left to its new worker", job.ID, job.Attempts)
+		return result, errors.Join(err, fmt.Errorf("job %d: %w", job.ID, ErrLeaseLost))
+	}
+
+	if err == nil || errors.Is(err, ErrCancelled) {
+		if dropErr := dropFiles(context.WithoutCancel(ctx), q.engine.db, job.ExecutionID); dropErr != nil {
+			q.engine.logger.Errorf("Failed to drop uploaded files of job %d: %v", job.ID, dropErr)
+		}
+	}
+
+	return result, err
+}
+
+// PurgeFiles drops the uploaded files of jobs that finished more than jobFileRetention
+// ago. It returns how many files were dropped.
+func (q *Queue) PurgeFiles(ctx context.Context) (int, error) {
+	query := `DELETE FROM job_files WHERE job_id IN (
+		SELECT id FROM jobs WHERE status IN ('done', 'dead', 'cancelled') AND updated_at < NOW() - make_interval(secs => $1)
+	)`
+	result, err := q.engine.db.ExecContext(ctx, query, jobFileRetention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge uploaded files: %w", err)
+	}
+	purged, _ := result.RowsAffected()
+	return int(purged), nil
+}
+
+// dropFiles deletes the uploaded files kept for a run
+func dropFiles(ctx context.Context, db DB, executionID int) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM job_files WHERE job_id IN (SELECT id FROM jobs WHERE execution_id = $1)`, executionID)
+	return err
+}
+
+func (q *Queue) process(ctx context.Context, job *Job) (interface{}, error) {
+	workflow, err := LoadWorkflow(ctx, q.engine.db, job.WorkflowID)
+	if err != nil {
+		q.engine.finishExecution(ctx, job.ExecutionID, "failed", err.Error(), nil, time.Now())
+		return nil, err
+	}
+	if job.Kind == jobResume {
+		return q.resume(ctx, job, *workflow)
+	}
+
+	trigger := job.Trigger
+	trigger.Files, err = q.restoreFiles(ctx, job.ID)
+	defer trigger.RemoveFiles()
+	if err != nil {
+		q.engine.finishExecution(ctx, job.ExecutionID, "failed", err.Error(), nil, time.Now())
+		return nil, err
+	}
+
+	exec := &Execution{
+		ID:       job.ExecutionID,
+		DB:       q.engine.db,
+		Logger:   q.engine.logger,
+		Workflow: *workflow,
+		Trigger:  trigger,
+		engine:   q.engine,
+	}
+	return q.engine.run(ctx, exec)
+}
+
+// restoreFiles writes a job's uploaded files back to temporary storage for its run
+func (q *Queue) restoreFiles(ctx context.Context, jobID int) (map[string]File, error) {
+	rows, err := q.engine.db.QueryContext(ctx, `SELECT field, filename, content_type, content FROM job_files WHERE job_id = $1`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch uploaded files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make(map[string]File)
+	for rows.Next() {
+		var file File
+		var content []byte
+		if err := rows.Scan(&file.Field, &file.Filename, &file.ContentType, &content); err != nil {
+			return files, fmt.Errorf("failed to read uploaded file: %w", err)
+		}
+
+		temp, err := os.CreateTemp("", "hookit-upload-*"+filepath.Ext(file.Filename))
+		if err != nil {
+			return files, fmt.Errorf("failed to restore uploaded file %s: %w", file.Filename, err)
+		}
+		_, err = temp.Write(content)
+		temp.Close()
+		file.Path = temp.Name()
+		file.Size = int64(len(content))
+		files[file.Field] = file
+		if err != nil {
+			return files, fmt.Errorf("failed to restore uploaded file %s: %w", file.Filename, err)
+		}
+	}
+
+	return files, rows.Err()
+}
+
+// heartbeat extends a job's lease until the returned function is called. Each beat
+// also picks up cancellations requested through other instances.
+func (q *Queue) heartbeat(ctx context.Context, job *Job) func() {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	ticker := time.NewTicker(jobLease / 3)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				query := `UPDATE jobs SET lease_expires_at = NOW() + make_interval(secs => $1), updated_at = NOW()
+					WHERE id = $2 AND status = 'running' AND lease_owner = $3 AND attempts = $4`
+				if _, err := q.engine.db.ExecContext(ctx, query, jobLease.Seconds(), job.ID, workerID, job.Attempts); err != nil && ctx.Err() == nil {
+					q.engine.logger.Errorf("Failed to extend lease of job %d: %v", job.ID, err)
+				}
+				q.engine.checkCancelled(ctx, job.ExecutionID)
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// RequeueExpired queues the jobs whose worker stopped heartbeating again, and gives
+// up on those that have used all their attempts. It returns how many were requeued.
+func (q *Queue) RequeueExpired(ctx context.Context) (int, error) {
+	result, err := q.engine.db.ExecContext(ctx, `UPDATE jobs SET status = 'queued', lease_owner = NULL, lease_expires_at = NULL,
+		updated_at = NOW()
+		WHERE status = 'running' AND lease_expires_at < NOW() AND attempts < max_attempts`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue expired jobs: %w", err)
+	}
+	requeued, _ := result.RowsAffected()
+
+	rows, err := q.engine.db.QueryContext(ctx, `UPDATE jobs SET status = 'dead', lease_owner = NULL, lease_expires_at = NULL,
+		updated_at = NOW()
+		WHERE status = 'running' AND lease_expires_at < NOW() RETURNING execution_id, attempts`)
+	if err != nil {
+		return int(requeued), fmt.Errorf("failed to expire jobs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var executionID, attempts int
+		if err := rows.Scan(&executionID, &attempts); err != nil {
+			return int(requeued), err
+		}
+		message := fmt.Sprintf("run abandoned after %d attempts whose worker stopped responding", attempts)
+		q.engine.finishExecution(ctx, executionID, "failed", message, nil, time.Now())
+	}
+
+	return int(requeued), rows.Err()
+}
+
+// jobTrigger rebuilds the trigger of a recorded run from its job, uploaded files
+// included. Runs that did not go through the queue only have their trigger body.
+func (q *Queue) jobTrigger(ctx context.Context, record *ExecutionRecord) (Trigger, error) {
+	var jobID int
+	var triggerJSON []byte
+	query := `SELECT id, trigger_data FROM jobs WHERE execution_id = $1 ORDER BY id LIMIT 1`
+	err := q.engine.db.QueryRowContext(ctx, query, record.ID).Scan(&jobID, &triggerJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		trigger := Trigger{Body: record.Input}
+		if record.TriggerType != nil {
+			trigger.Type = *record.TriggerType
+		}
+		return trigger, nil
+	}
+	if err != nil {
+		return Trigger{}, fmt.Errorf("failed to fetch trigger of execution %d: %w", record.ID, err)
+	}
+
+	var trigger Trigger
+	if err := json.Unmarshal(triggerJSON, &trigger); err != nil {
+		return Trigger{}, fmt.Errorf("invalid trigger for job %d: %w", jobID, err)
+	}
+	trigger.Files, err = q.restoreFiles(ctx, jobID)
+	return trigger, err
+}
@@ -0,0 +1,194 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// StepHandler implements a step type, or an action subtype of the "action" step.
+// In-house step types live in their own packages and register themselves from init().
+type StepHandler interface {
+	// Validate checks a step payload before the workflow is saved, and again once its
+	// templates are rendered before each run. Values holding {{ }} expressions should
+	// only be checked in their rendered form.
+	Validate(payload map[string]interface{}) error
+	// Execute runs the step against the data produced by the previous step and returns its output.
+	// When exec.DryRun() is true it must not write to databases, call out or send
+	// anything: it records what it would have done with exec.RecordEffect instead, or
+	// returns its output without it. Only handlers implementing DryRunner are run in
+	// dry runs.
+	Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error)
+	// Describe documents the step type and the output it produces
+	Describe() Description
+}
+
+// DryRunner is implemented by handlers whose Execute honours exec.DryRun(). Dry runs
+// refuse steps and actions whose handler does not report that it supports them, since
+// they could have side effects.
+type DryRunner interface {
+	SupportsDryRun() bool
+}
+
+// checkDryRun refuses a handler in a dry run unless it supports dry runs
+func checkDryRun(exec *Execution, kind, name string, handler StepHandler) error {
+	if !exec.DryRun() {
+		return nil
+	}
+	if runner, ok := handler.(DryRunner); ok && runner.SupportsDryRun() {
+		return nil
+	}
+	return fmt.Errorf("%s %s does not support dry runs", kind, name)
+}
+
+// Description documents a registered step type or action for clients building workflows
+type Description struct {
+	Type    string `json:"type"`
+	Summary string `json:"summary"`
+	Output  string `json:"output"`
+}
+
+type registry struct {
+	mu       sync.RWMutex
+	handlers map[string]StepHandler
+}
+
+var (
+	steps   = &registry{handlers: make(map[string]StepHandler)}
+	actions = &registry{handlers: make(map[string]StepHandler)}
+)
+
+// RegisterStep makes a step type available to workflows. It panics if the type is
+// registered twice, as database/sql does for drivers.
+func RegisterStep(stepType string, handler StepHandler) {
+	steps.register(stepType, handler)
+}
+
+// RegisterAction makes an actionType available to "action" steps
+func RegisterAction(actionType string, handler StepHandler) {
+	actions.register(actionType, handler)
+}
+
+// LookupStep returns the handler registered for a step type
+func LookupStep(stepType string) (StepHandler, error) {
+	handler, ok := steps.lookup(stepType)
+	if !ok {
+		return nil, fmt.Errorf("unknown step type: %s", stepType)
+	}
+	return handler, nil
+}
+
+// LookupAction returns the handler registered for an action type
+func LookupAction(actionType string) (StepHandler, error) {
+	handler, ok := actions.lookup(actionType)
+	if !ok {
+		return nil, fmt.Errorf("unknown action type: %s", actionType)
+	}
+	return handler, nil
+}
+
+// StepTypes describes every registered step type
+func StepTypes() []Description {
+	return steps.describe()
+}
+
+// ActionTypes describes every registered action type
+func ActionTypes() []Description {
+	return actions.describe()
+}
+
+// ValidateWorkflow checks a workflow's steps, its error handler steps and its settings
+// before it is saved. Error handler steps share step names with the workflow's steps
+// so they can address their outputs.
+func ValidateWorkflow(workflow Workflow) error {
+	names := make(map[string]bool)
+	if err := validateSteps(workflow.Steps, names); err != nil {
+		return err
+	}
+	if err := validateSteps(workflow.OnError, names); err != nil {
+		return fmt.Errorf("onError: %w", err)
+	}
+	if err := workflow.Settings.Validate(); err != nil {
+		return fmt.Errorf("settings: %w", err)
+	}
+	return nil
+}
+
+// ValidateSteps checks every step, including those nested in branches, against its
+// registered handler. Step names must be unique across the workflow because later
+// steps address earlier outputs by name, and dependencies must not form a cycle.
+func ValidateSteps(workflowSteps []Step) error {
+	return validateSteps(workflowSteps, make(map[string]bool))
+}
+
+func validateSteps(sequence []Step, names map[string]bool) error {
+	for _, step := range sequence {
+		if step.Name == "" {
+			return fmt.Errorf("every step needs a name")
+		}
+		if names[step.Name] {
+			return fmt.Errorf("duplicate step name '%s'", step.Name)
+		}
+		names[step.Name] = true
+
+		handler, err := LookupStep(step.Type)
+		if err != nil {
+			return fmt.Errorf("step '%s': %w", step.Name, err)
+		}
+		if err := handler.Validate(step.Payload); err != nil {
+			return fmt.Errorf("step '%s': %w", step.Name, err)
+		}
+		if _, err := retryPolicyFromPayload(step.Payload); err != nil {
+			return fmt.Errorf("step '%s': %w", step.Name, err)
+		}
+		if !templated(step.Payload["timeout"]) {
+			if _, err := durationOption(step.Payload, "timeout", 0); err != nil {
+				return fmt.Errorf("step '%s': %w", step.Name, err)
+			}
+		}
+
+		for _, branch := range step.branchNames() {
+			if err := validateSteps(step.Branches[branch], names); err != nil {
+				return fmt.Errorf("step '%s' branch '%s': %w", step.Name, branch, err)
+			}
+		}
+	}
+	return checkDependencies(sequence)
+}
+
+func (r *registry) register(name string, handler StepHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if handler == nil {
+		panic("engine: register handler is nil for " + name)
+	}
+	if _, dup := r.handlers[name]; dup {
+		panic("engine: register called twice for " + name)
+	}
+	r.handlers[name] = handler
+}
+
+func (r *registry) lookup(name string) (StepHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	handler, ok := r.handlers[name]
+	return handler, ok
+}
+
+func (r *registry) describe() []Description {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	descriptions := make([]Description, 0, len(r.handlers))
+	for name, handler := range r.handlers {
+		description := handler.Describe()
+		description.Type = name
+		descriptions = append(descriptions, description)
+	}
+	sort.Slice(descriptions, func(i, j int) bool { return descriptions[i].Type < descriptions[j].Type })
+
+	return descriptions
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// Error classes a retry policy can choose to retry
+const (
+	ClassNetwork   = "network"    // connection refused, reset, DNS failures
+	ClassTimeout   = "timeout"    // deadlines and I/O timeouts
+	ClassServer    = "server"     // HTTP 5xx responses
+	ClassRateLimit = "rate_limit" // HTTP 429 responses
+	ClassClient    = "client"     // other HTTP 4xx responses
+	ClassOther     = "other"      // everything else, such as invalid configuration
+)
+
+var errorClasses = map[string]bool{
+	ClassNetwork: true, ClassTimeout: true, ClassServer: true,
+	ClassRateLimit: true, ClassClient: true, ClassOther: true, "any": true,
+}
+
+// StepError is a step failure tagged with its class so retry policies can tell
+// transient failures from permanent ones
+type StepError struct {
+	Class string
+	Err   error
+}
+
+func (e *StepError) Error() string { return e.Err.Error() }
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// ClassifyError returns the class of a step failure
+func ClassifyError(err error) string {
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		return stepErr.Class
+	}
+	if errors.Is(err, ErrTimedOut) || errors.Is(err, context.DeadlineExceeded) {
+		return ClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ClassTimeout
+		}
+		return ClassNetwork
+	}
+	return ClassOther
+}
+
+// RetryPolicy is read from a step's "retry" payload, for example
+// {"maxAttempts": 5, "initialDelay": "500ms", "multiplier": 2, "maxDelay": "30s",
+// "jitter": 0.2, "retryOn": ["network", "timeout", "server"]}.
+// Delays are durations or milliseconds; jitter spreads each delay by up to that fraction.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	Multiplier   float64
+	MaxDelay     time.Duration
+	Jitter       float64
+	RetryOn      []string
+}
+
+// noRetry runs a step once
+var noRetry = RetryPolicy{MaxAttempts: 1}
+
+// retryPolicyFromPayload reads a step's retry policy. Steps without one run once.
+func retryPolicyFromPayload(payload map[string]interface{}) (RetryPolicy, error) {
+	raw, ok := payload["retry"]
+	if !ok || raw == nil {
+		return noRetry, nil
+	}
+	spec, ok := raw.(map[string]interface{})
+	if !ok {
+		return noRetry, fmt.Errorf("retry must be an object")
+	}
+
+	policy := RetryPolicy{
+		MaxAttempts:  3,
+		InitialDelay: time.Second,
+		Multiplier:   2,
+		MaxDelay:     30 * time.Second,
+		RetryOn:      []string{ClassNetwork, ClassTimeout, ClassServer, ClassRateLimit},
+	}
+
+	if value, ok := spec["maxAttempts"]; ok {
+		attempts, ok := value.(float64)
+		if !ok || attempts < 1 || attempts > 20 || attempts != math.Trunc(attempts) {
+			return noRetry, fmt.Errorf("retry maxAttempts must be a whole number from 1 to 20")
+		}
+		policy.MaxAttempts = int(attempts)
+	}
+
+	var err error
+	if policy.InitialDelay, err = durationOption(spec, "initialDelay", policy.InitialDelay); err != nil {
+		return noRetry, fmt.Errorf("retry %w", err)
+	}
+	if policy.MaxDelay, err = durationOption(spec, "maxDelay", policy.MaxDelay); err != nil {
+		return noRetry, fmt.Errorf("retry %w", err)
+	}
+
+	if value, ok := spec["multiplier"]; ok {
+		multiplier, ok := value.(float64)
+		if !ok || multiplier < 1 {
+			return noRetry, fmt.Errorf("retry multiplier must be a number of at least 1")
+		}
+		policy.Multiplier = multiplier
+	}
+
+	if value, ok := spec["jitter"]; ok {
+		jitter, ok := value.(float64)
+		if !ok || jitter < 0 || jitter > 1 {
+			return noRetry, fmt.Errorf("retry jitter must be between 0 and 1")
+		}
+		policy.Jitter = jitter
+	}
+
+	if value, ok := spec["retryOn"]; ok {
+		list, ok := value.([]interface{})
+		if !ok {
+			return noRetry, fmt.Errorf("retry retryOn must be a list of error classes")
+		}
+		policy.RetryOn = nil
+		for _, item := range list {
+			class, _ := item.(string)
+			if !errorClasses[class] {
+				return noRetry, fmt.Errorf("unknown error class %q in retryOn", item)
+			}
+			policy.RetryOn = append(policy.RetryOn, class)
+		}
+	}
+
+	return policy, nil
+}
+
+// durationOption reads a duration given as a string such as "500ms" or as milliseconds
+func durationOption(spec map[string]interface{}, key string, fallback time.Duration) (time.Duration, error) {
+	value, ok := spec[key]
+	if !ok {
+		return fallback, nil
+	}
+
+	var duration time.Duration
+	switch v := value.(type) {
+	case float64:
+		duration = time.Duration(v * float64(time.Millisecond))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be a duration such as \"2s\"", key)
+		}
+		duration = parsed
+	default:
+		return 0, fmt.Errorf("%s must be a duration such as \"2s\"", key)
+	}
+	if duration < 0 {
+		return 0, fmt.Errorf("%s cannot be negative", key)
+	}
+	return duration, nil
+}
+
+// retryable reports whether the policy retries an error of this class
+func (p RetryPolicy) retryable(err error) bool {
+	class := ClassifyError(err)
+	for _, retryOn := range p.RetryOn {
+		if retryOn == "any" || retryOn == class {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait after the given failed attempt
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(attempt-1))
+	if max := float64(p.MaxDelay); p.MaxDelay > 0 && delay > max {
+		delay = max
+	}
+	if p.Jitter > 0 {
+		delay *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
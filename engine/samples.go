@@ -0,0 +1,159 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maxTriggerSamples is how many recent trigger payloads are kept per workflow, besides
+// the pinned one
+const maxTriggerSamples = 10
+
+// TriggerSample is a captured webhook trigger a workflow can be tested with
+type TriggerSample struct {
+	ID         int       `json:"id"`
+	WorkflowID int       `json:"workflowId"`
+	Trigger    Trigger   `json:"trigger"`
+	Pinned     bool      `json:"pinned"`
+	CapturedAt time.Time `json:"capturedAt"`
+}
+
+// CaptureSample keeps a webhook trigger as a sample of its workflow and drops the
+// oldest unpinned samples beyond the last maxTriggerSamples. Uploaded files are not
+// kept, and credentials and signatures in its headers are redacted.
+func CaptureSample(ctx context.Context, db DB, workflowID int, trigger Trigger) error {
+	trigger.Files = nil
+	trigger.Headers = redactHeaders(trigger.Headers)
+	triggerJSON, err := json.Marshal(trigger)
+	if err != nil {
+		return fmt.Errorf("failed to encode trigger sample: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, `INSERT INTO trigger_samples (workflow_id, trigger_data) VALUES ($1, $2)`, workflowID, string(triggerJSON))
+	if err != nil {
+		return fmt.Errorf("failed to capture trigger sample: %w", err)
+	}
+
+	query := `DELETE FROM trigger_samples WHERE workflow_id = $1 AND NOT pinned AND id NOT IN (
+		SELECT id FROM trigger_samples WHERE workflow_id = $1 AND NOT pinned ORDER BY id DESC LIMIT $2
+	)`
+	if _, err := db.ExecContext(ctx, query, workflowID, maxTriggerSamples); err != nil {
+		return fmt.Errorf("failed to drop old trigger samples: %w", err)
+	}
+	return nil
+}
+
+const sampleColumns = `id, workflow_id, trigger_data, pinned, captured_at`
+
+// ListSamples returns the samples of a workflow, newest first
+func ListSamples(ctx context.Context, db DB, workflowID int) ([]TriggerSample, error) {
+	query := `SELECT ` + sampleColumns + ` FROM trigger_samples WHERE workflow_id = $1 ORDER BY id DESC`
+	rows, err := db.QueryContext(ctx, query, workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch trigger samples: %w", err)
+	}
+	defer rows.Close()
+
+	samples := []TriggerSample{}
+	for rows.Next() {
+		sample, err := scanSample(rows)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, *sample)
+	}
+	return samples, rows.Err()
+}
+
+// LoadSample fetches one sample of a workflow
+func LoadSample(ctx context.Context, db DB, workflowID, sampleID int) (*TriggerSample, error) {
+	query := `SELECT ` + sampleColumns + ` FROM trigger_samples WHERE workflow_id = $1 AND id = $2`
+	sample, err := scanSample(db.QueryRowContext(ctx, query, workflowID, sampleID))
+	if err != nil {
+		return nil, fmt.Errorf("trigger sample not found: %w", err)
+	}
+	return sample, nil
+}
+
+// DefaultSample returns the sample a workflow is tested with: the pinned one, or the
+// most recent one when none is pinned. It returns nil when there are no samples.
+func DefaultSample(ctx context.Context, db DB, workflowID int) (*TriggerSample, error) {
+	query := `SELECT ` + sampleColumns + ` FROM trigger_samples WHERE workflow_id = $1
+		ORDER BY pinned DESC, id DESC LIMIT 1`
+	sample, err := scanSample(db.QueryRowContext(ctx, query, workflowID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch trigger sample: %w", err)
+	}
+	return sample, nil
+}
+
+// txBeginner is implemented by databases that can run transactions, such as the
+// *sql.DB behind gofr's ctx.SQL
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// PinSample makes a sample the workflow's test fixture, unpinning any other. The old
+// pin is cleared before the new one is set, in one transaction, since only one sample
+// per workflow may be pinned at any point.
+func PinSample(ctx context.Context, db DB, workflowID, sampleID int) error {
+	beginner, ok := db.(txBeginner)
+	if !ok {
+		return fmt.Errorf("failed to pin trigger sample: the database does not support transactions")
+	}
+	tx, err := beginner.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to pin trigger sample: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := UnpinSample(ctx, tx, workflowID); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, `UPDATE trigger_samples SET pinned = TRUE WHERE workflow_id = $1 AND id = $2`, workflowID, sampleID)
+	if err != nil {
+		return fmt.Errorf("failed to pin trigger sample: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to pin trigger sample: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("trigger sample %d not found in workflow %d", sampleID, workflowID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to pin trigger sample: %w", err)
+	}
+	return nil
+}
+
+// UnpinSample unpins the workflow's test fixture. It becomes an ordinary sample and
+// is dropped once newer samples replace it.
+func UnpinSample(ctx context.Context, db DB, workflowID int) error {
+	_, err := db.ExecContext(ctx, `UPDATE trigger_samples SET pinned = FALSE WHERE workflow_id = $1 AND pinned`, workflowID)
+	if err != nil {
+		return fmt.Errorf("failed to unpin trigger sample: %w", err)
+	}
+	return nil
+}
+
+func scanSample(row rowScanner) (*TriggerSample, error) {
+	var sample TriggerSample
+	var triggerJSON []byte
+	if err := row.Scan(&sample.ID, &sample.WorkflowID, &triggerJSON, &sample.Pinned, &sample.CapturedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(triggerJSON, &sample.Trigger); err != nil {
+		return nil, fmt.Errorf("invalid trigger sample %d: %w", sample.ID, err)
+	}
+	return &sample, nil
+}
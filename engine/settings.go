@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Execution modes of webhook runs
+const (
+	ModeSync          = "sync"            // run inside the request and return the result
+	ModeAsync         = "async"           // return 202 with an execution ID and run in the background
+	ModeSyncThenAsync = "sync_then_async" // wait up to syncTimeout for the result, then answer 202
+)
+
+// Concurrency options of a workflow
+const (
+	OverflowQueue  = "queue"  // runs over a concurrency limit wait for a free slot
+	OverflowReject = "reject" // runs over a concurrency limit are turned away with 429
+	SingletonSkip  = "skip"   // a run is dropped while a previous run is queued or running
+	SingletonQueue = "queue"  // a run waits until the previous run is done
+)
+
+// defaultSyncTimeout is how long sync_then_async webhooks wait for a result
+const defaultSyncTimeout = 10 * time.Second
+
+// defaultIdempotencyWindow is how long a webhook delivery is remembered by its key
+const defaultIdempotencyWindow = 24 * time.Hour
+
+// Settings are the run options of a workflow, stored as JSON in workflows.settings
+type Settings struct {
+	// Deadline bounds a whole run, such as "5m"
+	Deadline string `json:"deadline,omitempty"`
+	// ErrorWorkflowID is a workflow of the same user run when a run fails
+	ErrorWorkflowID int `json:"errorWorkflowId,omitempty"`
+	// Mode is how webhook runs answer their caller: sync, async or sync_then_async
+	Mode string `json:"mode,omitempty"`
+	// SyncTimeout is how long a sync_then_async webhook waits before answering 202
+	SyncTimeout string `json:"syncTimeout,omitempty"`
+	// MaxConcurrency bounds how many runs of the workflow execute at once; 0 is unlimited
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// Overflow is what happens to runs over a concurrency limit: queue or reject
+	Overflow string `json:"overflow,omitempty"`
+	// Singleton lets only one run of the workflow go at a time, and skips or queues the others
+	Singleton string `json:"singleton,omitempty"`
+	// RateLimit throttles webhook calls to the workflow
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+	// IPRateLimit throttles webhook calls to the workflow from each source IP
+	IPRateLimit *RateLimit `json:"ipRateLimit,omitempty"`
+	// IdempotencyKey is the path of a delivery ID in the webhook body, such as
+	// "entry[0].changes[0].value.leadgen_id", used when there is no Idempotency-Key header
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// IdempotencyWindow is how long a delivery is remembered, such as "24h"
+	IdempotencyWindow string `json:"idempotencyWindow,omitempty"`
+}
+
+// RateLimit is a token bucket: Requests tokens are added every Per, up to Burst, and
+// each webhook call takes one, for example {"requests": 100, "per": "1m", "burst": 20}
+type RateLimit struct {
+	Requests int    `json:"requests"`
+	Per      string `json:"per"`
+	// Burst is how many calls may arrive at once, Requests by default
+	Burst int `json:"burst,omitempty"`
+}
+
+// Validate checks a rate limit before it is saved
+func (r RateLimit) Validate() error {
+	if r.Requests <= 0 {
+		return fmt.Errorf("requests must be a positive number")
+	}
+	per, err := time.ParseDuration(r.Per)
+	if err != nil || per <= 0 {
+		return fmt.Errorf("per must be a positive duration such as \"1m\"")
+	}
+	if r.Burst < 0 {
+		return fmt.Errorf("burst cannot be negative")
+	}
+	return nil
+}
+
+// Rate returns how many tokens are added per second
+func (r RateLimit) Rate() float64 {
+	per, err := time.ParseDuration(r.Per)
+	if err != nil || per <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / per.Seconds()
+}
+
+// Capacity returns the size of the bucket
+func (r RateLimit) Capacity() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Requests
+}
+
+// Validate checks the settings before they are saved
+func (s Settings) Validate() error {
+	if s.Deadline != "" {
+		deadline, err := time.ParseDuration(s.Deadline)
+		if err != nil || deadline <= 0 {
+			return fmt.Errorf("deadline must be a positive duration such as \"5m\"")
+		}
+	}
+	if s.ErrorWorkflowID < 0 {
+		return fmt.Errorf("errorWorkflowId must be a workflow ID")
+	}
+	switch s.Mode {
+	case "", ModeSync, ModeAsync, ModeSyncThenAsync:
+	default:
+		return fmt.Errorf("mode must be sync, async or sync_then_async")
+	}
+	if s.SyncTimeout != "" {
+		timeout, err := time.ParseDuration(s.SyncTimeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("syncTimeout must be a positive duration such as \"10s\"")
+		}
+	}
+	if s.MaxConcurrency < 0 {
+		return fmt.Errorf("maxConcurrency cannot be negative")
+	}
+	switch s.Overflow {
+	case "", OverflowQueue, OverflowReject:
+	default:
+		return fmt.Errorf("overflow must be queue or reject")
+	}
+	switch s.Singleton {
+	case "", SingletonSkip, SingletonQueue:
+	default:
+		return fmt.Errorf("singleton must be skip or queue")
+	}
+	if _, err := splitPath(s.IdempotencyKey); err != nil {
+		return fmt.Errorf("idempotencyKey: %w", err)
+	}
+	if s.IdempotencyWindow != "" {
+		window, err := time.ParseDuration(s.IdempotencyWindow)
+		if err != nil || window <= 0 {
+			return fmt.Errorf("idempotencyWindow must be a positive duration such as \"24h\"")
+		}
+	}
+	if s.RateLimit != nil {
+		if err := s.RateLimit.Validate(); err != nil {
+			return fmt.Errorf("rateLimit %w", err)
+		}
+	}
+	if s.IPRateLimit != nil {
+		if err := s.IPRateLimit.Validate(); err != nil {
+			return fmt.Errorf("ipRateLimit %w", err)
+		}
+	}
+	return nil
+}
+
+// ExecutionMode returns how webhook runs answer their caller, sync by default
+func (s Settings) ExecutionMode() string {
+	if s.Mode == "" {
+		return ModeSync
+	}
+	return s.Mode
+}
+
+// SyncWait returns how long a sync_then_async webhook waits for the result
+func (s Settings) SyncWait() time.Duration {
+	timeout, err := time.ParseDuration(s.SyncTimeout)
+	if err != nil || timeout <= 0 {
+		return defaultSyncTimeout
+	}
+	return timeout
+}
+
+// IdempotencyRetention returns how long a webhook delivery is remembered by its key
+func (s Settings) IdempotencyRetention() time.Duration {
+	window, err := time.ParseDuration(s.IdempotencyWindow)
+	if err != nil || window <= 0 {
+		return defaultIdempotencyWindow
+	}
+	return window
+}
+
+// concurrencyLimit returns how many runs of the workflow may execute at once, or 0
+// when they are unlimited. Singleton workflows run one at a time.
+func (s Settings) concurrencyLimit() int {
+	if s.Singleton != "" {
+		return 1
+	}
+	return s.MaxConcurrency
+}
+
+// deadline returns the run deadline, or 0 when runs are unbounded
+func (s Settings) deadline() time.Duration {
+	deadline, err := time.ParseDuration(s.Deadline)
+	if err != nil {
+		return 0
+	}
+	return deadline
+}
+
+// Scan reads settings from a JSONB column
+func (s *Settings) Scan(src interface{}) error {
+	*s = Settings{}
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return fmt.Errorf("cannot read workflow settings from %T", src)
+}
+
+// Value stores settings in a JSONB column
+func (s Settings) Value() (driver.Value, error) {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
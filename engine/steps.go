@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+)
+
+func init() {
+	RegisterStep("trigger", triggerStep{})
+	RegisterStep("parse", parseStep{})
+	RegisterStep("filter", filterStep{})
+	RegisterStep("action", actionStep{})
+	RegisterStep("branch", branchStep{})
+	RegisterStep("loop", loopStep{})
+	RegisterStep("workflow", workflowStep{})
+}
+
+// triggerStep marks how a workflow is started. The trigger has already fired by the
+// time steps run, so it passes its input through.
+type triggerStep struct{}
+
+func (triggerStep) Validate(payload map[string]interface{}) error {
+	return nil
+}
+
+func (triggerStep) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	return input, nil
+}
+
+func (triggerStep) Describe() Description {
+	return Description{
+		Summary: "Starts the workflow from a webhook or a schedule",
+		Output:  "The trigger payload, unchanged",
+	}
+}
+
+func (triggerStep) SupportsDryRun() bool {
+	return true
+}
+
+// parseStep turns CSV text from its input into an array of typed records
+type parseStep struct{}
+
+func (parseStep) Validate(payload map[string]interface{}) error {
+	if inputType, _ := payload["inputType"].(string); inputType != "" && inputType != "csv" {
+		return fmt.Errorf("unsupported inputType: %s", inputType)
+	}
+	_, err := csvOptionsFromPayload(payload)
+	return err
+}
+
+func (parseStep) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	opts, err := csvOptionsFromPayload(step.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := csvSource(exec, step.Payload, input)
+	if err != nil {
+		return nil, err
+	}
+
+	records, rowErrors, err := parseCSV(text, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %w", err)
+	}
+	exec.Logger.Infof("Parsed %d records with %d row errors", len(records), len(rowErrors))
+
+	errorList := make([]interface{}, len(rowErrors))
+	for i, rowError := range rowErrors {
+		errorList[i] = map[string]interface{}{"row": rowError.Row, "error": rowError.Error}
+	}
+
+	return map[string]interface{}{
+		"records":    records,
+		"errors":     errorList,
+		"rowCount":   len(records),
+		"errorCount": len(rowErrors),
+	}, nil
+}
+
+func (parseStep) Describe() Description {
+	return Description{
+		Summary: "Parses CSV from the trigger input or an uploaded file using header, delimiter, quote, skipRows and columnTypes options",
+		Output:  `{"records": [...], "errors": [{"row", "error"}], "rowCount", "errorCount"}`,
+	}
+}
+
+func (parseStep) SupportsDryRun() bool {
+	return true
+}
+
+// csvSource finds the CSV text for a parse step. It reads the raw request body when
+// the payload's "source" is "raw", the uploaded file named by "file", or the input
+// itself, or the input's "field" (default "csv"), falling back to the first uploaded
+// file and then to a raw text/csv body.
+func csvSource(exec *Execution, payload map[string]interface{}, input interface{}) (string, error) {
+	if source, _ := payload["source"].(string); source == "raw" {
+		return string(exec.Trigger.Raw), nil
+	}
+
+	if name, _ := payload["file"].(string); name != "" {
+		file, ok := exec.Trigger.Files[name]
+		if !ok {
+			return "", fmt.Errorf("no uploaded file named %q", name)
+		}
+		return readUpload(file)
+	}
+
+	field, _ := payload["field"].(string)
+	if field == "" {
+		field = "csv"
+	}
+
+	switch data := input.(type) {
+	case string:
+		return data, nil
+	case []byte:
+		return string(data), nil
+	case map[string]interface{}:
+		switch value := data[field].(type) {
+		case string:
+			return value, nil
+		case []byte:
+			return string(value), nil
+		}
+	}
+
+	if len(exec.Trigger.Files) > 0 {
+		names := make([]string, 0, len(exec.Trigger.Files))
+		for name := range exec.Trigger.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return readUpload(exec.Trigger.Files[names[0]])
+	}
+
+	if exec.Trigger.ContentType == "text/csv" {
+		return string(exec.Trigger.Raw), nil
+	}
+
+	return "", fmt.Errorf("no CSV found in input field %q", field)
+}
+
+func readUpload(file File) (string, error) {
+	content, err := os.ReadFile(file.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read uploaded file %s: %w", file.Filename, err)
+	}
+	return string(content), nil
+}
+
+// actionStep dispatches to the handler registered for its actionType
+type actionStep struct{}
+
+func (actionStep) Validate(payload map[string]interface{}) error {
+	actionType, _ := payload["actionType"].(string)
+	if actionType == "" {
+		return fmt.Errorf("actionType is required")
+	}
+
+	handler, err := LookupAction(actionType)
+	if err != nil {
+		return err
+	}
+
+	return handler.Validate(payload)
+}
+
+func (actionStep) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	actionType, _ := step.Payload["actionType"].(string)
+
+	handler, err := LookupAction(actionType)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDryRun(exec, "action type", actionType, handler); err != nil {
+		return nil, err
+	}
+
+	return handler.Execute(ctx, exec, step, input)
+}
+
+func (actionStep) Describe() Description {
+	return Description{
+		Summary: "Performs the side effect selected by actionType",
+		Output:  "Depends on the action type",
+	}
+}
+
+func (actionStep) SupportsDryRun() bool {
+	return true
+}
@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxWorkflowDepth bounds how deeply workflow steps can call other workflows
+const maxWorkflowDepth = 5
+
+// workflowStep runs another workflow of the same user and returns its output. The
+// called workflow receives "input" when set, otherwise this step's input, and its
+// run is recorded as a child of the calling execution.
+type workflowStep struct{}
+
+func (workflowStep) Validate(payload map[string]interface{}) error {
+	switch id := payload["workflowId"].(type) {
+	case float64:
+		if id < 1 || id != float64(int(id)) {
+			return fmt.Errorf("workflowId must be a workflow ID")
+		}
+	case string:
+		// A template resolves to the ID when the step runs
+		if !templated(id) {
+			if _, err := strconv.Atoi(id); err != nil {
+				return fmt.Errorf("workflowId must be a workflow ID")
+			}
+		}
+	default:
+		return fmt.Errorf("workflow step requires a workflowId")
+	}
+	return nil
+}
+
+func (workflowStep) Execute(ctx context.Context, exec *Execution, step Step, input interface{}) (interface{}, error) {
+	workflowID, err := strconv.Atoi(toString(step.Payload["workflowId"]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflowId %v", step.Payload["workflowId"])
+	}
+
+	callers := append(append([]int{}, exec.callers...), exec.Workflow.ID)
+	for i, caller := range callers {
+		if caller == workflowID {
+			chain := make([]string, 0, len(callers)-i+1)
+			for _, id := range callers[i:] {
+				chain = append(chain, strconv.Itoa(id))
+			}
+			chain = append(chain, strconv.Itoa(workflowID))
+			return nil, fmt.Errorf("workflow cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if len(callers) > maxWorkflowDepth {
+		return nil, fmt.Errorf("sub-workflows are nested more than %d deep", maxWorkflowDepth)
+	}
+
+	workflow, err := LoadWorkflow(ctx, exec.DB, workflowID)
+	if err != nil || workflow.UserID != exec.Workflow.UserID {
+		return nil, fmt.Errorf("workflow %d not found", workflowID)
+	}
+
+	childInput := input
+	if mapped, ok := step.Payload["input"]; ok {
+		childInput = mapped
+	}
+
+	exec.Logger.Infof("Workflow %d calling workflow %d from step %s", exec.Workflow.ID, workflowID, step.Name)
+
+	child := &Execution{
+		ParentID: exec.ID,
+		DB:       exec.DB,
+		Logger:   exec.Logger,
+		Workflow: *workflow,
+		Trigger:  Trigger{Type: "workflow", Body: childInput},
+		engine:   exec.engine,
+		callers:  callers,
+		stop:     exec.stop,
+		trace:    exec.trace,
+	}
+
+	return exec.engine.run(ctx, child)
+}
+
+func (workflowStep) Describe() Description {
+	return Description{
+		Summary: `Runs another of your workflows ("workflowId") with "input", or this step's input, as its trigger body`,
+		Output:  "The output of the called workflow",
+	}
+}
+
+func (workflowStep) SupportsDryRun() bool {
+	return true
+}
@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// templatePattern matches {{ expression }} placeholders in step payload strings
+var templatePattern = regexp.MustCompile(`\{\{\s*(.*?)\s*\}\}`)
+
+// templated reports whether a payload value holds {{ }} expressions. Such values can
+// only be checked once they are rendered, when the step runs.
+func templated(value interface{}) bool {
+	text, ok := value.(string)
+	return ok && templatePattern.MatchString(text)
+}
+
+// RenderPayload resolves {{ }} expressions in every string of a step payload against
+// the scope. A string that is exactly one expression keeps the type of the value it
+// resolves to; otherwise values are interpolated as text.
+//
+// Expressions are a path into the scope followed by optional pipes, for example
+// {{ trigger.body.email }}, {{ steps.parse.records[0].id }} or {{ now | date "2006-01-02" }}.
+func RenderPayload(payload map[string]interface{}, scope map[string]interface{}) (map[string]interface{}, error) {
+	rendered, err := renderValue(payload, scope)
+	if err != nil {
+		return nil, err
+	}
+	result, _ := rendered.(map[string]interface{})
+	return result, nil
+}
+
+func renderValue(value interface{}, scope map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return RenderString(v, scope)
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			rendered, err := renderValue(item, scope)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			result[key] = rendered
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			rendered, err := renderValue(item, scope)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = rendered
+		}
+		return result, nil
+	default:
+		return value, nil
+	}
+}
+
+// RenderString resolves the {{ }} expressions in a single string
+func RenderString(text string, scope map[string]interface{}) (interface{}, error) {
+	matches := templatePattern.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, nil
+	}
+
+	// A lone expression keeps its type so numbers, lists and objects survive rendering
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(text) {
+		return evaluateExpression(text[matches[0][2]:matches[0][3]], scope)
+	}
+
+	var out strings.Builder
+	last := 0
+	for _, match := range matches {
+		out.WriteString(text[last:match[0]])
+		value, err := evaluateExpression(text[match[2]:match[3]], scope)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(textValue(value))
+		last = match[1]
+	}
+	out.WriteString(text[last:])
+
+	return out.String(), nil
+}
+
+// evaluateExpression resolves "path | fn arg | fn"
+func evaluateExpression(expression string, scope map[string]interface{}) (interface{}, error) {
+	stages := splitOutsideQuotes(expression, '|')
+
+	head := strings.TrimSpace(stages[0])
+	value, found := literal(head)
+	if !found {
+		value, found = LookupPath(scope, head)
+	}
+
+	for _, stage := range stages[1:] {
+		fields := splitOutsideQuotes(strings.TrimSpace(stage), ' ')
+		name := fields[0]
+
+		var args []interface{}
+		for _, field := range fields[1:] {
+			if field == "" {
+				continue
+			}
+			arg, ok := literal(field)
+			if !ok {
+				arg, _ = LookupPath(scope, field)
+			}
+			args = append(args, arg)
+		}
+
+		var err error
+		value, found, err = applyTemplateFunc(name, value, found, args)
+		if err != nil {
+			return nil, fmt.Errorf("{{ %s }}: %w", expression, err)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("{{ %s }}: %s not found", expression, head)
+	}
+
+	return value, nil
+}
+
+// applyTemplateFunc applies one pipe stage
+func applyTemplateFunc(name string, value interface{}, found bool, args []interface{}) (interface{}, bool, error) {
+	if name == "default" {
+		if (!found || value == nil || value == "") && len(args) > 0 {
+			return args[0], true, nil
+		}
+		return value, found, nil
+	}
+
+	if !found {
+		return nil, false, nil
+	}
+
+	switch name {
+	case "date":
+		t, err := toTime(value)
+		if err != nil {
+			return nil, false, err
+		}
+		layout := time.RFC3339
+		if len(args) > 0 {
+			layout = textValue(args[0])
+		}
+		return t.Format(layout), true, nil
+	case "upper":
+		return strings.ToUpper(textValue(value)), true, nil
+	case "lower":
+		return strings.ToLower(textValue(value)), true, nil
+	case "trim":
+		return strings.TrimSpace(textValue(value)), true, nil
+	case "urlencode":
+		return url.QueryEscape(textValue(value)), true, nil
+	case "json":
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, false, err
+		}
+		return string(encoded), true, nil
+	case "length":
+		switch v := value.(type) {
+		case []interface{}:
+			return len(v), true, nil
+		case map[string]interface{}:
+			return len(v), true, nil
+		default:
+			return len(textValue(v)), true, nil
+		}
+	}
+
+	return nil, false, fmt.Errorf("unknown function %q", name)
+}
+
+// literal parses a quoted string or a number
+func literal(token string) (interface{}, bool) {
+	if len(token) >= 2 && (token[0] == '"' || token[0] == '\'') && token[len(token)-1] == token[0] {
+		return token[1 : len(token)-1], true
+	}
+	if n, err := strconv.ParseFloat(token, 64); err == nil {
+		return n, true
+	}
+	return nil, false
+}
+
+// splitOutsideQuotes splits on sep except inside quoted strings
+func splitOutsideQuotes(text string, sep rune) []string {
+	var parts []string
+	var current strings.Builder
+	var quote rune
+
+	for _, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			current.WriteRune(r)
+		case r == sep:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+
+	return append(parts, current.String())
+}
+
+// textValue formats a resolved value for interpolation into a string
+func textValue(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}, []interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	default:
+		return toString(v)
+	}
+}
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func templateScope() map[string]interface{} {
+	return map[string]interface{}{
+		"trigger": map[string]interface{}{
+			"body": map[string]interface{}{
+				"email": " Ada@Example.com ",
+				"count": 3.0,
+				"empty": "",
+				"query": "a b&c",
+				"at":    "2024-03-01T10:00:00Z",
+			},
+		},
+		"steps": map[string]interface{}{
+			"parse": map[string]interface{}{
+				"records": []interface{}{
+					map[string]interface{}{"id": 7.0, "name": "Ada"},
+					map[string]interface{}{"id": 8.0, "name": "Grace"},
+				},
+			},
+		},
+	}
+}
+
+func TestRenderString(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    interface{}
+		wantErr string
+	}{
+		{name: "no expressions", text: "plain text", want: "plain text"},
+		{name: "lone expression keeps a number", text: "{{ trigger.body.count }}", want: 3.0},
+		{name: "lone expression keeps an object", text: "{{steps.parse.records.1}}", want: map[string]interface{}{"id": 8.0, "name": "Grace"}},
+		{name: "bracket index", text: "{{ steps.parse.records[0].name }}", want: "Ada"},
+		{name: "interpolated into text", text: "id={{ steps.parse.records[0].id }}, n={{ trigger.body.count }}", want: "id=7, n=3"},
+		{name: "object interpolated as JSON", text: "row: {{ steps.parse.records[1] }}", want: `row: {"id":8,"name":"Grace"}`},
+		{name: "pipes run in order", text: "{{ trigger.body.email | trim | lower }}", want: "ada@example.com"},
+		{name: "upper", text: "{{ steps.parse.records[0].name | upper }}", want: "ADA"},
+		{name: "urlencode", text: "q={{ trigger.body.query | urlencode }}", want: "q=a+b%26c"},
+		{name: "json", text: "{{ steps.parse.records[0] | json }}", want: `{"id":7,"name":"Ada"}`},
+		{name: "length of a list", text: "{{ steps.parse.records | length }}", want: 2},
+		{name: "date with a layout", text: `{{ trigger.body.at | date "2006-01-02" }}`, want: "2024-03-01"},
+		{name: "quoted pipe stays in the argument", text: `{{ trigger.body.at | date "2006|01" }}`, want: "2024|03"},
+		{name: "default for a missing path", text: `{{ trigger.body.missing | default "none" }}`, want: "none"},
+		{name: "default for an empty value", text: "{{ trigger.body.empty | default 0 }}", want: 0.0},
+		{name: "default keeps a present value", text: `{{ trigger.body.count | default "none" }}`, want: 3.0},
+		{name: "literal string", text: `{{ 'fixed' | upper }}`, want: "FIXED"},
+		{name: "missing path", text: "hi {{ trigger.body.name }}", wantErr: "trigger.body.name not found"},
+		{name: "missing path through a pipe", text: "{{ trigger.body.name | upper }}", wantErr: "not found"},
+		{name: "unknown function", text: "{{ trigger.body.count | shout }}", wantErr: `unknown function "shout"`},
+		{name: "date of a non-date", text: "{{ trigger.body.email | date }}", wantErr: "unrecognised date"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderString(tt.text, templateScope())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RenderString error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenderString: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RenderString = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPayload(t *testing.T) {
+	payload := map[string]interface{}{
+		"to":      "{{ trigger.body.email | trim }}",
+		"retries": 2.0,
+		"enabled": true,
+		"ids":     []interface{}{"{{ steps.parse.records[0].id }}", "{{ steps.parse.records[1].id }}"},
+		"body":    map[string]interface{}{"subject": "Hello {{ steps.parse.records[0].name }}"},
+	}
+
+	got, err := RenderPayload(payload, templateScope())
+	if err != nil {
+		t.Fatalf("RenderPayload: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"to":      "Ada@Example.com",
+		"retries": 2.0,
+		"enabled": true,
+		"ids":     []interface{}{7.0, 8.0},
+		"body":    map[string]interface{}{"subject": "Hello Ada"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RenderPayload = %#v\nwant %#v", got, want)
+	}
+	if payload["to"] != "{{ trigger.body.email | trim }}" {
+		t.Error("RenderPayload changed the payload it was given")
+	}
+}
+
+func TestRenderPayloadErrorNamesKey(t *testing.T) {
+	payload := map[string]interface{}{"body": map[string]interface{}{"subject": "{{ trigger.body.name }}"}}
+
+	_, err := RenderPayload(payload, templateScope())
+	if err == nil || !strings.HasPrefix(err.Error(), "body: subject: ") {
+		t.Errorf("RenderPayload error = %v, want it to name body: subject", err)
+	}
+}
+
+func TestTemplated(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{"{{ trigger.body.id }}", true},
+		{"id-{{x}}", true},
+		{"{ not a template }", false},
+		{"plain", false},
+		{3.0, false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := templated(tt.value); got != tt.want {
+			t.Errorf("templated(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DB is the part of the gofr SQL datasource used by the engine. ctx.SQL satisfies it.
+type DB interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Logger is the part of the gofr logger used by the engine. ctx.Logger satisfies it.
+type Logger interface {
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// errorBranch is the branch name that marks a workflow's error handler steps
+const errorBranch = "on_error"
+
+// Workflow is a stored workflow together with its ordered steps. OnError holds the
+// steps run when a run fails.
+type Workflow struct {
+	ID         int      `json:"id"`
+	Name       string   `json:"name"`
+	WebhookURL string   `json:"webhookUrl"`
+	UserID     int      `json:"userId"`
+	Settings   Settings `json:"settings"`
+	Steps      []Step   `json:"steps"`
+	OnError    []Step   `json:"onError,omitempty"`
+}
+
+// Step is a single unit of work in a workflow, shared by webhook and scheduled runs.
+// Branch steps hold the steps of each named branch in Branches. Steps that list the
+// sibling steps they depend on in DependsOn run as a graph rather than in order.
+type Step struct {
+	ID         int                    `json:"id"`
+	WorkflowID int                    `json:"workflowId,omitempty"`
+	Name       string                 `json:"name"`
+	Type       string                 `json:"type"`
+	Payload    map[string]interface{} `json:"payload"`
+	StepOrder  int                    `json:"stepOrder"`
+	DependsOn  []string               `json:"dependsOn,omitempty"`
+	Branches   map[string][]Step      `json:"branches,omitempty"`
+}
+
+// branchNames returns the step's branch names in a stable order
+func (s Step) branchNames() []string {
+	names := make([]string, 0, len(s.Branches))
+	for name := range s.Branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadWorkflow fetches a workflow and its steps by ID
+func LoadWorkflow(ctx context.Context, db DB, workflowID int) (*Workflow, error) {
+	query := `SELECT id, name, webhook_url, user_id, settings FROM workflows WHERE id = $1`
+	return loadWorkflow(ctx, db, query, workflowID)
+}
+
+// WorkflowOwnedBy reports whether a workflow belongs to the user with the given email
+func WorkflowOwnedBy(ctx context.Context, db DB, workflowID int, email string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM workflows w JOIN users u ON u.id = w.user_id WHERE w.id = $1 AND u.email = $2)`
+	var owned bool
+	if err := db.QueryRowContext(ctx, query, workflowID, email).Scan(&owned); err != nil {
+		return false, fmt.Errorf("failed to check owner of workflow %d: %w", workflowID, err)
+	}
+	return owned, nil
+}
+
+// LoadWorkflowByWebhook fetches a workflow and its steps by its webhook URL
+func LoadWorkflowByWebhook(ctx context.Context, db DB, webhookURL string) (*Workflow, error) {
+	query := `SELECT id, name, webhook_url, user_id, settings FROM workflows WHERE webhook_url = $1`
+	return loadWorkflow(ctx, db, query, webhookURL)
+}
+
+func loadWorkflow(ctx context.Context, db DB, query string, key interface{}) (*Workflow, error) {
+	var workflow Workflow
+	var userID sql.NullInt64
+	err := db.QueryRowContext(ctx, query, key).Scan(&workflow.ID, &workflow.Name, &workflow.WebhookURL, &userID, &workflow.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("workflow not found: %w", err)
+	}
+	workflow.UserID = int(userID.Int64)
+
+	workflow.Steps, workflow.OnError, err = LoadSteps(ctx, db, workflow.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &workflow, nil
+}
+
+// LoadSteps fetches the steps of a workflow and its error handler steps ordered by
+// step_order, nesting the steps of each branch under their parent step
+func LoadSteps(ctx context.Context, db DB, workflowID int) ([]Step, []Step, error) {
+	query := `SELECT id, workflow_id, name, step_type, payload, step_order, parent_step_id, branch, depends_on
+		FROM steps WHERE workflow_id = $1 ORDER BY step_order, id`
+	rows, err := db.QueryContext(ctx, query, workflowID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch workflow steps: %w", err)
+	}
+	defer rows.Close()
+
+	type stepRow struct {
+		step     Step
+		parentID sql.NullInt64
+		branch   sql.NullString
+	}
+
+	var stepRows []stepRow
+	for rows.Next() {
+		var row stepRow
+		var payloadJSON, dependsOnJSON []byte
+		err := rows.Scan(&row.step.ID, &row.step.WorkflowID, &row.step.Name, &row.step.Type, &payloadJSON,
+			&row.step.StepOrder, &row.parentID, &row.branch, &dependsOnJSON)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse step data: %w", err)
+		}
+
+		if len(payloadJSON) > 0 {
+			err = json.Unmarshal(payloadJSON, &row.step.Payload)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid payload for step %s: %w", row.step.Name, err)
+			}
+		}
+		if row.step.Payload == nil {
+			row.step.Payload = make(map[string]interface{})
+		}
+		if len(dependsOnJSON) > 0 {
+			err = json.Unmarshal(dependsOnJSON, &row.step.DependsOn)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid dependencies for step %s: %w", row.step.Name, err)
+			}
+		}
+
+		stepRows = append(stepRows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Group children by parent and branch, then assemble the tree from the top
+	children := make(map[int]map[string][]Step)
+	var topLevel, onError []Step
+	for _, row := range stepRows {
+		if !row.parentID.Valid {
+			if row.branch.String == errorBranch {
+				onError = append(onError, row.step)
+			} else {
+				topLevel = append(topLevel, row.step)
+			}
+			continue
+		}
+		parentID := int(row.parentID.Int64)
+		if children[parentID] == nil {
+			children[parentID] = make(map[string][]Step)
+		}
+		children[parentID][row.branch.String] = append(children[parentID][row.branch.String], row.step)
+	}
+
+	var attach func(sequence []Step) []Step
+	attach = func(sequence []Step) []Step {
+		for i := range sequence {
+			branches, ok := children[sequence[i].ID]
+			if !ok {
+				continue
+			}
+			sequence[i].Branches = make(map[string][]Step, len(branches))
+			for branch, branchSteps := range branches {
+				sequence[i].Branches[branch] = attach(branchSteps)
+			}
+		}
+		return sequence
+	}
+
+	return attach(topLevel), attach(onError), nil
+}
+
+// SaveSteps stores a workflow's step tree and its error handler steps. Steps carrying
+// an ID are updated, new steps are inserted and steps no longer present are deleted.
+// The returned steps carry the IDs of inserted rows.
+func SaveSteps(ctx context.Context, db DB, workflowID int, workflowSteps, errorSteps []Step) ([]Step, []Step, error) {
+	var keep []int
+	saved, err := saveStepTree(ctx, db, workflowID, workflowSteps, nil, nil, &keep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	branch := errorBranch
+	savedErrorSteps, err := saveStepTree(ctx, db, workflowID, errorSteps, nil, &branch, &keep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = deleteRemovedSteps(ctx, db, workflowID, keep)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to delete removed steps: %w", err)
+	}
+
+	return saved, savedErrorSteps, nil
+}
+
+func saveStepTree(ctx context.Context, db DB, workflowID int, sequence []Step, parentID *int, branch *string, keep *[]int) ([]Step, error) {
+	saved := make([]Step, len(sequence))
+	for i, step := range sequence {
+		payloadJSON, err := json.Marshal(step.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal step payload: %w", err)
+		}
+		dependsOn := step.DependsOn
+		if dependsOn == nil {
+			dependsOn = []string{}
+		}
+		dependsOnJSON, err := json.Marshal(dependsOn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal step dependencies: %w", err)
+		}
+
+		if step.ID != 0 {
+			// Update existing step
+			updateStepQuery := `UPDATE steps SET name = $1, step_type = $2, payload = $3, step_order = $4, parent_step_id = $5, branch = $6,
+				depends_on = $7 WHERE id = $8 AND workflow_id = $9`
+			_, err = db.ExecContext(ctx, updateStepQuery, step.Name, step.Type, string(payloadJSON), step.StepOrder, parentID, branch,
+				string(dependsOnJSON), step.ID, workflowID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to update step with ID %d: %w", step.ID, err)
+			}
+		} else {
+			// Insert new step
+			insertStepQuery := `INSERT INTO steps (workflow_id, name, step_type, payload, step_order, parent_step_id, branch, depends_on)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+			err = db.QueryRowContext(ctx, insertStepQuery, workflowID, step.Name, step.Type, string(payloadJSON), step.StepOrder, parentID, branch,
+				string(dependsOnJSON)).Scan(&step.ID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to insert step %s: %w", step.Name, err)
+			}
+		}
+		*keep = append(*keep, step.ID)
+
+		if len(step.Branches) > 0 {
+			branches := make(map[string][]Step, len(step.Branches))
+			for _, name := range step.branchNames() {
+				branchName := name
+				branchSteps, err := saveStepTree(ctx, db, workflowID, step.Branches[name], &step.ID, &branchName, keep)
+				if err != nil {
+					return nil, err
+				}
+				branches[name] = branchSteps
+			}
+			step.Branches = branches
+		}
+
+		saved[i] = step
+	}
+
+	return saved, nil
+}
+
+// deleteRemovedSteps deletes the steps of a workflow whose IDs are not listed
+func deleteRemovedSteps(ctx context.Context, db DB, workflowID int, stepIDs []int) error {
+	if len(stepIDs) == 0 {
+		// If no steps are specified, delete all steps for this workflow
+		deleteQuery := `DELETE FROM steps WHERE workflow_id = $1`
+		_, err := db.ExecContext(ctx, deleteQuery, workflowID)
+		return err
+	}
+
+	// Convert the stepIDs slice into a comma-separated string
+	stepIDStrings := make([]string, len(stepIDs))
+	for i, id := range stepIDs {
+		stepIDStrings[i] = strconv.Itoa(id)
+	}
+	idList := strings.Join(stepIDStrings, ",") // e.g., "1,2,3"
+
+	deleteQuery := fmt.Sprintf(`DELETE FROM steps WHERE workflow_id = $1 AND id NOT IN (%s)`, idList)
+	_, err := db.ExecContext(ctx, deleteQuery, workflowID)
+	return err
+}
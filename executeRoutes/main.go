@@ -1,5 +1,136 @@
 package executeroutes
 
-// func executeWebhook(ctx *gofr.Context) (interface{}, error) {
+import (
+	"errors"
+	"fmt"
+	"github/Somnathumapathi/gofrhack/authRoutes"
+	"github/Somnathumapathi/gofrhack/engine"
+	"github/Somnathumapathi/gofrhack/models"
+	"net/http"
+	"strconv"
 
-// }
+	"gofr.dev/pkg/gofr"
+)
+
+// GetExecution reports the status of a run and, once it has finished, its output.
+// Asynchronous webhooks answer with the ID to poll here.
+func GetExecution(ctx *gofr.Context) (interface{}, error) {
+	id, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid execution ID: %w", err)
+	}
+
+	return engine.LoadExecution(ctx, ctx.SQL, id)
+}
+
+// ResumeExecution queues a failed execution to run again from the step it failed at,
+// reusing the checkpointed outputs of the steps before it. {"config": {...}} replaces
+// the failed step's configuration for this run; with "save": true the workflow keeps
+// it. The answer carries the execution to poll at /executions/{id}. Only the owner of
+// the workflow, signed in with a bearer token, can resume its runs.
+func ResumeExecution(ctx *gofr.Context) (interface{}, error) {
+	id, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid execution ID: %w", err)
+	}
+	if _, err := authRoutes.SignedInUser(ctx); err != nil {
+		return nil, err
+	}
+
+	// An empty body resumes with the workflow as it is
+	var requestBody struct {
+		Config map[string]interface{} `json:"config"`
+		Save   bool                   `json:"save"`
+	}
+	_ = ctx.Bind(&requestBody)
+
+	record, err := engine.LoadExecution(ctx, ctx.SQL, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := authRoutes.RequireWorkflowOwner(ctx, record.WorkflowID); err != nil {
+		return nil, err
+	}
+	if err := record.Resumable(); err != nil {
+		return nil, err
+	}
+
+	workflow, err := engine.LoadWorkflow(ctx, ctx.SQL, record.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	var stepConfig map[string]interface{}
+	if requestBody.Config != nil {
+		if record.FailedStep == nil {
+			return nil, fmt.Errorf("execution %d has no failed step to change", id)
+		}
+		if !workflow.SetStepPayload(*record.FailedStep, requestBody.Config) {
+			return nil, fmt.Errorf("step %s no longer exists in workflow %d", *record.FailedStep, workflow.ID)
+		}
+		if err := engine.ValidateWorkflow(*workflow); err != nil {
+			return nil, fmt.Errorf("invalid config for step %s: %w", *record.FailedStep, err)
+		}
+		stepConfig = map[string]interface{}{*record.FailedStep: requestBody.Config}
+	}
+
+	_, err = engine.NewQueue(engine.New(ctx.SQL, ctx.Logger)).Resume(ctx, record, *workflow, stepConfig)
+	switch {
+	case errors.Is(err, engine.ErrSkipped):
+		return nil, models.StatusError{Status: http.StatusConflict, Message: fmt.Sprintf("execution %d was not resumed: %v", id, err)}
+	case errors.Is(err, engine.ErrConcurrencyLimit):
+		return nil, models.StatusError{Status: http.StatusTooManyRequests, Message: fmt.Sprintf("execution %d was not resumed: %v", id, err)}
+	case err != nil:
+		return nil, fmt.Errorf("failed to resume execution %d: %w", id, err)
+	}
+
+	// The queued run carries the new config, so it is only saved once the resume is accepted
+	if stepConfig != nil && requestBody.Save {
+		_, _, err = engine.SaveSteps(ctx, ctx.SQL, workflow.ID, workflow.Steps, workflow.OnError)
+		if err != nil {
+			return nil, fmt.Errorf("execution %d was resumed, but its config could not be saved: %w", id, err)
+		}
+	}
+
+	return map[string]interface{}{
+		"status":      "queued",
+		"executionId": id,
+		"resumedAt":   record.FailedStep,
+		"statusUrl":   fmt.Sprintf("/executions/%d", id),
+	}, nil
+}
+
+// CancelExecution stops a queued run, or cancels the current step of a running one.
+// Only the owner of the workflow, signed in with a bearer token, can cancel its
+// runs, and is recorded as the one who cancelled it.
+func CancelExecution(ctx *gofr.Context) (interface{}, error) {
+	id, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid execution ID: %w", err)
+	}
+
+	if _, err := authRoutes.SignedInUser(ctx); err != nil {
+		return nil, err
+	}
+
+	record, err := engine.LoadExecution(ctx, ctx.SQL, id)
+	if err != nil {
+		return nil, err
+	}
+	cancelledBy, err := authRoutes.RequireWorkflowOwner(ctx, record.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := engine.CancelExecution(ctx, ctx.SQL, id, cancelledBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"executionId": id,
+		"status":      status,
+		"cancelledBy": cancelledBy,
+		"statusUrl":   fmt.Sprintf("/executions/%d", id),
+	}, nil
+}
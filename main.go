@@ -2,9 +2,13 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"github/Somnathumapathi/gofrhack/authRoutes"
 	"github/Somnathumapathi/gofrhack/cmRoutes"
 	"github/Somnathumapathi/gofrhack/cronRoutes"
+	"github/Somnathumapathi/gofrhack/deadLetterRoutes"
+	executeroutes "github/Somnathumapathi/gofrhack/executeRoutes"
+	"github/Somnathumapathi/gofrhack/sampleRoutes"
 	"github/Somnathumapathi/gofrhack/services"
 	"github/Somnathumapathi/gofrhack/testRoutes"
 	"github/Somnathumapathi/gofrhack/workflowRoutes"
@@ -41,37 +45,20 @@ func authMiddleware() func(handler http.Handler) http.Handler {
 				inner.ServeHTTP(w, r)
 			} else {
 				//user has to be authenticated here
-				authHeader := r.Header["Authorization"]
-				if authHeader == nil {
-					http.Error(w, "Not authorized. Please login!", http.StatusUnauthorized)
-					return
-				}
-				if authHeader[0] == "" {
-					http.Error(w, "Not authorized. Please login!", http.StatusUnauthorized)
-					return
-				}
-				tokenStr := strings.Split(authHeader[0], " ")
-				if len(tokenStr) == 1 {
+				tokenStr, ok := bearerToken(r)
+				if !ok {
 					http.Error(w, "Not authorized. Please login!", http.StatusUnauthorized)
 					return
 				}
 
-				claims := &Claims{}
-
-				// Parse the JWT string and store the result in `claims`.
-				// Note that we are passing the key in this method as well. This method will return an error
-				// if the token is invalid (if it has expired according to the expiry time we set on sign in),
-				// or if the signature does not match
-				tkn, err := jwt.ParseWithClaims(tokenStr[1], claims, func(token *jwt.Token) (any, error) {
-					return jwtKey, nil
-				})
-				if err != nil || !tkn.Valid {
+				email, err := tokenUser(tokenStr)
+				if err != nil {
 					http.Error(w, "You are unauthorized. Please login again!", http.StatusUnauthorized)
 					return
 				}
 
 				//token is valid and not expired hence a valid and authorized user
-				newContext := context.WithValue(r.Context(), "userEmail", claims.Username)
+				newContext := context.WithValue(r.Context(), "userEmail", email)
 				newR := r.Clone(newContext)
 
 				// sends the request to the next middleware/request-handler
@@ -81,13 +68,74 @@ func authMiddleware() func(handler http.Handler) http.Handler {
 	}
 }
 
+// identityMiddleware stores the user a request is signed in as under "userEmail" in
+// its context. Unlike authMiddleware it lets requests without a valid token through,
+// so webhooks stay public; handlers that act for a user check that one is set.
+func identityMiddleware() func(handler http.Handler) http.Handler {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tokenStr, ok := bearerToken(r); ok {
+				if email, err := tokenUser(tokenStr); err == nil {
+					r = r.Clone(context.WithValue(r.Context(), "userEmail", email))
+				}
+			}
+			inner.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, bool) {
+	tokenStr := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(tokenStr) == 1 || tokenStr[1] == "" {
+		return "", false
+	}
+	return tokenStr[1], true
+}
+
+// tokenUser returns the email of the user a JWT was issued to
+func tokenUser(tokenStr string) (string, error) {
+	claims := &Claims{}
+
+	// Parse the JWT string and store the result in `claims`.
+	// Note that we are passing the key in this method as well. This method will return an error
+	// if the token is invalid (if it has expired according to the expiry time we set on sign in),
+	// or if the signature does not match
+	tkn, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (any, error) {
+		return jwtKey, nil
+	})
+	if err != nil || !tkn.Valid {
+		return "", fmt.Errorf("invalid token")
+	}
+	return claims.Username, nil
+}
+
 func main() {
 	// initialise gofr object
 	app := gofr.New()
 
+	// Only believe X-Forwarded-For on webhook calls from these proxies
+	if err := workflowRoutes.TrustProxies(app.Config.Get("TRUSTED_PROXIES")); err != nil {
+		app.Logger().Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+
+	// Keep the raw webhook request around for file uploads
+	app.UseMiddleware(workflowRoutes.WebhookMiddleware())
+
+	// Know which user a request is signed in as, without requiring one
+	app.UseMiddleware(identityMiddleware())
+
+	// Count webhook calls turned away by rate limits, by workflow and scope
+	app.Metrics().NewCounter(workflowRoutes.RateLimitedMetric, "Webhook calls rejected by a rate limit")
+
 	// Initialize and start cron service for scheduled workflows
 	cronService := services.NewCronService(app)
 
+	// Run queued webhook and scheduled runs every second, four at a time
+	jobWorker := services.NewJobWorker(4)
+	app.AddCronJob("* * * * * *", "job_worker", jobWorker.Poll)
+	app.AddCronJob("0 0 * * * *", "job_files_purge", jobWorker.PurgeFiles)
+
 	// Start scheduled workflows when the server starts
 	// We'll do this after the server is running to ensure database connections are ready
 	go func() {
@@ -116,18 +164,34 @@ func main() {
 	// Auth routes
 	app.POST("/user/register", authRoutes.RegisterUser)
 	app.POST("/user/login", authRoutes.LoginUser)
+	app.GET("/user/settings", authRoutes.GetUserSettings)
+	app.PUT("/user/settings", authRoutes.UpdateUserSettings)
 
 	// Workflow routes (with auth middleware would be added here)
 	app.POST("/workflow/create", workflowRoutes.CreateWorkflow)
 	app.GET("/workflow/{id}", workflowRoutes.GetWorkflow)
 	app.GET("/workflows/{uid}", workflowRoutes.GetWorkflows) // List all workflows for a user
 	app.PUT("/workflow/{id}", workflowRoutes.UpdateWorkflow)
+	app.GET("/step-types", workflowRoutes.GetStepTypes)
 
 	// Cron/Schedule management routes
 	app.GET("/scheduled-workflows", cronRoutes.GetScheduledWorkflows)
 	app.GET("/workflow/{workflowId}/executions", cronRoutes.GetWorkflowExecutions)
+	app.GET("/workflow/{workflowId}/executions/{executionId}/steps", cronRoutes.GetExecutionSteps)
 	app.PUT("/workflow/{workflowId}/schedule", cronRoutes.ToggleWorkflowSchedule)
 
+	// Dead-letter queue of failed runs
+	app.GET("/workflow/{workflowId}/dead-letters", deadLetterRoutes.GetDeadLetters)
+	app.POST("/workflow/{workflowId}/dead-letters/replay", deadLetterRoutes.ReplayDeadLetters)
+	app.GET("/dead-letters/{id}", deadLetterRoutes.GetDeadLetter)
+	app.POST("/dead-letters/{id}/replay", deadLetterRoutes.ReplayDeadLetter)
+	app.DELETE("/dead-letters/{id}", deadLetterRoutes.DiscardDeadLetter)
+
+	// Captured webhook payloads to test workflows with
+	app.GET("/workflow/{workflowId}/samples", sampleRoutes.GetSamples)
+	app.POST("/workflow/{workflowId}/samples/{sampleId}/pin", sampleRoutes.PinSample)
+	app.DELETE("/workflow/{workflowId}/samples/pin", sampleRoutes.UnpinSample)
+
 	// Credit management
 	app.POST("/buyCredits", cmRoutes.AddCreditsHandler)
 	app.GET("/user/{userId}/credits", cmRoutes.GetUserCredits)
@@ -136,9 +200,14 @@ func main() {
 	app.POST("/test/create-scheduled-workflow", testRoutes.CreateTestScheduledWorkflow)
 	app.POST("/test/execute/{workflowId}", testRoutes.TestCronExecution)
 	app.GET("/test/cron-status", testRoutes.GetCronStatus)
+	app.POST("/workflow/{id}/test", testRoutes.TestRunWorkflow)
+	app.POST("/workflow/{id}/steps/{step}/preview", testRoutes.PreviewStep)
 
 	// Webhook execution endpoint
 	app.POST("/webhook/{workflowId}", workflowRoutes.ExecuteWorkflow)
+	app.GET("/executions/{id}", executeroutes.GetExecution)
+	app.POST("/executions/{id}/resume", executeroutes.ResumeExecution)
+	app.POST("/executions/{id}/cancel", executeroutes.CancelExecution)
 
 	app.Run()
 }
@@ -0,0 +1,12 @@
+-- Allow steps to be nested in the named branches of a branch step
+ALTER TABLE steps
+ADD COLUMN IF NOT EXISTS parent_step_id INTEGER REFERENCES steps (id) ON DELETE CASCADE;
+
+ALTER TABLE steps
+ADD COLUMN IF NOT EXISTS branch VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_steps_parent_step_id
+    ON steps (parent_step_id);
+
+COMMENT ON COLUMN steps.parent_step_id IS 'Branch step this step belongs to, NULL for top-level steps';
+COMMENT ON COLUMN steps.branch IS 'Name of the parent step branch this step runs in';
@@ -0,0 +1,5 @@
+-- Let steps declare the steps they depend on so a sequence can run as a graph
+ALTER TABLE steps
+ADD COLUMN IF NOT EXISTS depends_on JSONB NOT NULL DEFAULT '[]';
+
+COMMENT ON COLUMN steps.depends_on IS 'Names of sibling steps whose output this step waits for';
@@ -0,0 +1,18 @@
+-- Record every run, including sub-workflow calls linked to the run that made them
+ALTER TABLE workflow_executions
+ADD COLUMN IF NOT EXISTS parent_execution_id INTEGER REFERENCES workflow_executions (id) ON DELETE SET NULL;
+
+ALTER TABLE workflow_executions
+ADD COLUMN IF NOT EXISTS trigger_type VARCHAR(50);
+
+ALTER TABLE workflow_executions
+ADD COLUMN IF NOT EXISTS input_data JSONB;
+
+ALTER TABLE workflow_executions
+ADD COLUMN IF NOT EXISTS output_data JSONB;
+
+CREATE INDEX IF NOT EXISTS idx_workflow_executions_parent_execution_id
+    ON workflow_executions (parent_execution_id);
+
+COMMENT ON COLUMN workflow_executions.parent_execution_id IS 'Execution whose workflow step started this run, NULL for top-level runs';
+COMMENT ON COLUMN workflow_executions.trigger_type IS 'webhook, schedule or workflow';
@@ -0,0 +1,24 @@
+-- Record every attempt at running a step, so retried steps show each try
+CREATE TABLE IF NOT EXISTS step_executions (
+    id SERIAL PRIMARY KEY,
+    execution_id INTEGER NOT NULL,
+    step_id INTEGER,
+    step_name VARCHAR(255) NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 1,
+    status VARCHAR(50) NOT NULL, -- success, failed, halted
+    error_message TEXT,
+    error_class VARCHAR(50), -- network, timeout, server, rate_limit, client, other
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    duration_ms INTEGER,
+
+    CONSTRAINT fk_step_executions_execution
+        FOREIGN KEY (execution_id)
+        REFERENCES workflow_executions (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_step_executions_execution_id
+    ON step_executions (execution_id);
+
+COMMENT ON TABLE step_executions IS 'One row per attempt at running a step of a recorded execution';
@@ -0,0 +1,5 @@
+-- Run options of a workflow, such as its deadline
+ALTER TABLE workflows
+ADD COLUMN IF NOT EXISTS settings JSONB NOT NULL DEFAULT '{}';
+
+COMMENT ON COLUMN workflows.settings IS 'Run options such as {"deadline": "5m"}';
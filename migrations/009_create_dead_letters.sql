@@ -0,0 +1,27 @@
+-- Keep failed runs with their trigger so they can be inspected and replayed
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id SERIAL PRIMARY KEY,
+    workflow_id INTEGER NOT NULL,
+    execution_id INTEGER REFERENCES workflow_executions (id) ON DELETE SET NULL,
+    trigger_data JSONB NOT NULL, -- type, body, headers, contentType and raw body
+    failed_step VARCHAR(255),
+    error_message TEXT NOT NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending', -- pending, replayed
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    replayed_at TIMESTAMP WITH TIME ZONE,
+    replay_execution_id INTEGER REFERENCES workflow_executions (id) ON DELETE SET NULL,
+
+    CONSTRAINT fk_dead_letters_workflow
+        FOREIGN KEY (workflow_id)
+        REFERENCES workflows (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_dead_letters_workflow_id
+    ON dead_letters (workflow_id, status);
+
+-- Link replays to the execution they replay
+ALTER TABLE workflow_executions
+ADD COLUMN IF NOT EXISTS replay_of_execution_id INTEGER REFERENCES workflow_executions (id) ON DELETE SET NULL;
+
+COMMENT ON TABLE dead_letters IS 'Failed runs kept with their trigger for inspection and replay';
@@ -0,0 +1,37 @@
+-- Durable queue of workflow runs. Workers claim jobs with FOR UPDATE SKIP LOCKED and
+-- hold a lease they extend while running; jobs with an expired lease are queued again.
+CREATE TABLE IF NOT EXISTS jobs (
+    id SERIAL PRIMARY KEY,
+    execution_id INTEGER NOT NULL REFERENCES workflow_executions (id) ON DELETE CASCADE,
+    workflow_id INTEGER NOT NULL,
+    trigger_data JSONB NOT NULL, -- type, body, headers, contentType and raw body
+    status VARCHAR(50) NOT NULL DEFAULT 'pending', -- pending, queued, running, done, dead
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 3,
+    lease_owner VARCHAR(255),
+    lease_expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_jobs_workflow
+        FOREIGN KEY (workflow_id)
+        REFERENCES workflows (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_queued ON jobs (id) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_jobs_running ON jobs (lease_expires_at) WHERE status = 'running';
+
+-- Uploaded files of a job, kept until the job is deleted
+CREATE TABLE IF NOT EXISTS job_files (
+    id SERIAL PRIMARY KEY,
+    job_id INTEGER NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+    field VARCHAR(255) NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255),
+    content BYTEA NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_files_job_id ON job_files (job_id);
+
+COMMENT ON TABLE jobs IS 'Durable queue of webhook and scheduled workflow runs';
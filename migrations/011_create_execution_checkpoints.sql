@@ -0,0 +1,21 @@
+-- Keep the output of each completed top-level step so failed runs can be resumed
+CREATE TABLE IF NOT EXISTS execution_checkpoints (
+    id SERIAL PRIMARY KEY,
+    execution_id INTEGER NOT NULL,
+    step_name VARCHAR(255) NOT NULL,
+    output_data JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_execution_checkpoints_execution
+        FOREIGN KEY (execution_id)
+        REFERENCES workflow_executions (id)
+        ON DELETE CASCADE,
+    CONSTRAINT uq_execution_checkpoints_step
+        UNIQUE (execution_id, step_name)
+);
+
+-- Remember the step a failed run stopped at
+ALTER TABLE workflow_executions
+ADD COLUMN IF NOT EXISTS failed_step VARCHAR(255);
+
+COMMENT ON TABLE execution_checkpoints IS 'Step outputs of a run, used to resume it from the step that failed';
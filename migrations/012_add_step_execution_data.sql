@@ -0,0 +1,6 @@
+-- Keep what each step attempt was given and what it produced
+ALTER TABLE step_executions
+ADD COLUMN IF NOT EXISTS input_data JSONB,
+ADD COLUMN IF NOT EXISTS output_data JSONB;
+
+COMMENT ON COLUMN step_executions.status IS 'success, failed, halted, or restored for steps a resumed run took from a checkpoint';
@@ -0,0 +1,5 @@
+-- Record who cancelled a run and when. A running run is cancelled once its current
+-- step is done, so cancelled_at is set before its status becomes 'cancelled'.
+ALTER TABLE workflow_executions
+ADD COLUMN IF NOT EXISTS cancelled_by VARCHAR(255),
+ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP WITH TIME ZONE;
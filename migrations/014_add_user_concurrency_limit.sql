@@ -0,0 +1,6 @@
+-- Bound how many runs of all of a user's workflows execute at once; NULL is unlimited.
+-- Per-workflow limits live in workflows.settings (maxConcurrency, overflow, singleton).
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS max_concurrent_executions INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_workflow_status ON jobs (workflow_id, status);
@@ -0,0 +1,14 @@
+-- Remember webhook deliveries by their idempotency key so retried deliveries return
+-- the original execution's outcome instead of running again
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    workflow_id INTEGER NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    execution_id INTEGER REFERENCES workflow_executions (id) ON DELETE CASCADE, -- NULL while the run is being queued
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (workflow_id, idempotency_key),
+    CONSTRAINT fk_idempotency_keys_workflow
+        FOREIGN KEY (workflow_id)
+        REFERENCES workflows (id)
+        ON DELETE CASCADE
+);
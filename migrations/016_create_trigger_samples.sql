@@ -0,0 +1,22 @@
+-- Keep the last webhook triggers of each workflow as samples to test it with. One
+-- sample per workflow can be pinned as its test fixture.
+CREATE TABLE IF NOT EXISTS trigger_samples (
+    id SERIAL PRIMARY KEY,
+    workflow_id INTEGER NOT NULL,
+    trigger_data JSONB NOT NULL, -- type, body, headers, contentType and raw body
+    pinned BOOLEAN NOT NULL DEFAULT FALSE,
+    captured_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+
+    CONSTRAINT fk_trigger_samples_workflow
+        FOREIGN KEY (workflow_id)
+        REFERENCES workflows (id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_trigger_samples_workflow_id
+    ON trigger_samples (workflow_id, id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_trigger_samples_pinned
+    ON trigger_samples (workflow_id) WHERE pinned;
+
+COMMENT ON TABLE trigger_samples IS 'Recent webhook triggers per workflow, used as test fixtures';
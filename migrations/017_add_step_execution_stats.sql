@@ -0,0 +1,4 @@
+-- Keep the figures a step attempt reports about its work, such as the records a filter
+-- kept and dropped
+ALTER TABLE step_executions
+ADD COLUMN IF NOT EXISTS stats JSONB;
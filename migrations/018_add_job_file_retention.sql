@@ -0,0 +1,6 @@
+-- Uploaded files are dropped once a run succeeds or is cancelled; those of failed runs
+-- are kept for a week so the run can be resumed or replayed
+CREATE INDEX IF NOT EXISTS idx_jobs_finished
+    ON jobs (updated_at) WHERE status IN ('done', 'dead', 'cancelled');
+
+COMMENT ON TABLE job_files IS 'Uploaded files of a job, dropped when its run succeeds or is cancelled, or a week after a failed run';
@@ -0,0 +1,4 @@
+-- Resumed runs go through the job queue as jobs of their original execution
+ALTER TABLE jobs
+ADD COLUMN IF NOT EXISTS kind VARCHAR(20) NOT NULL DEFAULT 'run', -- run, resume
+ADD COLUMN IF NOT EXISTS step_config JSONB; -- step payloads a resumed run uses instead of the saved ones
@@ -0,0 +1,11 @@
+-- Dead letters now keep their trigger without credentials or signatures; redact the
+-- headers of the ones stored before
+UPDATE dead_letters
+SET trigger_data = jsonb_set(trigger_data, '{headers}', (
+    SELECT jsonb_object_agg(key, CASE
+        WHEN key ~* '(authorization|cookie|token|secret|password|signature|api-key|apikey|session)' THEN '"[redacted]"'::jsonb
+        ELSE value END)
+    FROM jsonb_each(trigger_data->'headers')
+))
+WHERE jsonb_typeof(trigger_data->'headers') = 'object'
+  AND trigger_data->'headers' <> '{}'::jsonb;
@@ -32,3 +32,25 @@ type LoginBody struct {
 }
 
 // Claims : type for jwt body
+
+// UserSettings are the settings a user can change for their own account. A nil
+// MaxConcurrentExecutions leaves the number of runs going at once across the
+// user's workflows unlimited.
+type UserSettings struct {
+	MaxConcurrentExecutions *int `json:"maxConcurrentExecutions"`
+}
+
+// StatusError is an error answered with a specific HTTP status. gofr takes the status
+// from its StatusCode method.
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e StatusError) Error() string {
+	return e.Message
+}
+
+func (e StatusError) StatusCode() int {
+	return e.Status
+}
@@ -0,0 +1,77 @@
+package sampleRoutes
+
+import (
+	"fmt"
+	"github/Somnathumapathi/gofrhack/authRoutes"
+	"github/Somnathumapathi/gofrhack/engine"
+	"strconv"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// GetSamples lists the webhook payloads captured for a workflow, newest first. The
+// pinned one is kept as the workflow's test fixture. Sample routes are only open to
+// the signed-in owner of the workflow.
+func GetSamples(ctx *gofr.Context) (interface{}, error) {
+	workflowID, err := strconv.Atoi(ctx.PathParam("workflowId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow ID: %w", err)
+	}
+	if _, err := authRoutes.RequireWorkflowOwner(ctx, workflowID); err != nil {
+		return nil, err
+	}
+
+	samples, err := engine.ListSamples(ctx, ctx.SQL, workflowID)
+	if err != nil {
+		ctx.Logger.Errorf("Error querying trigger samples: %v", err)
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"samples":    samples,
+		"count":      len(samples),
+		"workflowId": workflowID,
+	}, nil
+}
+
+// PinSample makes a captured payload the workflow's test fixture. Test runs and step
+// previews use it by default, and it is kept when newer payloads arrive.
+func PinSample(ctx *gofr.Context) (interface{}, error) {
+	workflowID, err := strconv.Atoi(ctx.PathParam("workflowId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow ID: %w", err)
+	}
+	if _, err := authRoutes.RequireWorkflowOwner(ctx, workflowID); err != nil {
+		return nil, err
+	}
+	sampleID, err := strconv.Atoi(ctx.PathParam("sampleId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sample ID: %w", err)
+	}
+
+	if err := engine.PinSample(ctx, ctx.SQL, workflowID, sampleID); err != nil {
+		return nil, err
+	}
+
+	return engine.LoadSample(ctx, ctx.SQL, workflowID, sampleID)
+}
+
+// UnpinSample removes the workflow's test fixture, so test runs use its latest payload
+func UnpinSample(ctx *gofr.Context) (interface{}, error) {
+	workflowID, err := strconv.Atoi(ctx.PathParam("workflowId"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow ID: %w", err)
+	}
+	if _, err := authRoutes.RequireWorkflowOwner(ctx, workflowID); err != nil {
+		return nil, err
+	}
+
+	if err := engine.UnpinSample(ctx, ctx.SQL, workflowID); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"message":    "Sample unpinned",
+		"workflowId": workflowID,
+	}, nil
+}
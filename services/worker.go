@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"github/Somnathumapathi/gofrhack/engine"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// JobWorker runs jobs from the durable job queue. Poll is registered as a cron job that
+// fires every second; each tick requeues jobs whose worker died and claims queued jobs
+// while the worker has free slots.
+type JobWorker struct {
+	slots chan struct{}
+}
+
+// NewJobWorker creates a worker that runs up to concurrency jobs at once
+func NewJobWorker(concurrency int) *JobWorker {
+	return &JobWorker{slots: make(chan struct{}, concurrency)}
+}
+
+// PurgeFiles drops the uploaded files kept for finished jobs once their retention is over
+func (w *JobWorker) PurgeFiles(c *gofr.Context) {
+	purged, err := engine.NewQueue(engine.New(c.SQL, c.Logger)).PurgeFiles(c)
+	if err != nil {
+		c.Logger.Errorf("Failed to purge uploaded files: %v", err)
+		return
+	}
+	if purged > 0 {
+		c.Logger.Infof("Purged %d uploaded files of finished jobs", purged)
+	}
+}
+
+// Poll claims and starts queued jobs
+func (w *JobWorker) Poll(c *gofr.Context) {
+	queue := engine.NewQueue(engine.New(c.SQL, c.Logger))
+
+	if requeued, err := queue.RequeueExpired(c); err != nil {
+		c.Logger.Errorf("Failed to requeue expired jobs: %v", err)
+	} else if requeued > 0 {
+		c.Logger.Infof("Requeued %d jobs whose lease expired", requeued)
+	}
+
+	for {
+		select {
+		case w.slots <- struct{}{}:
+		default:
+			return // every slot is busy
+		}
+
+		job, err := queue.Claim(c)
+		if err != nil || job == nil {
+			<-w.slots
+			if err != nil {
+				c.Logger.Errorf("Failed to claim job: %v", err)
+			}
+			return
+		}
+
+		go func() {
+			defer func() { <-w.slots }()
+			c.Logger.Infof("Running job %d (execution %d of workflow %d)", job.ID, job.ExecutionID, job.WorkflowID)
+			if _, err := queue.Process(context.WithoutCancel(c), job); err != nil {
+				c.Logger.Errorf("Job %d failed: %v", job.ID, err)
+			}
+		}()
+	}
+}
@@ -2,6 +2,8 @@ package testRoutes
 
 import (
 	"fmt"
+	"github/Somnathumapathi/gofrhack/authRoutes"
+	"github/Somnathumapathi/gofrhack/engine"
 	"strconv"
 
 	"gofr.dev/pkg/gofr"
@@ -152,3 +154,98 @@ func GetCronStatus(ctx *gofr.Context) (interface{}, error) {
 		"message":             "Cron system status retrieved successfully",
 	}, nil
 }
+
+// TestRunWorkflow dry-runs a workflow against a sample payload, given as
+// {"payload": {...}, "headers": {...}} or as a captured sample with {"sampleId": n}.
+// Without either it uses the workflow's pinned sample, or its latest delivery.
+// Database, api_call and email actions are not carried out; the response shows each
+// step's input and output and the SQL statements, HTTP requests and emails they would
+// have produced. A step whose type does not support dry runs fails the test run
+// instead of running.
+func TestRunWorkflow(ctx *gofr.Context) (interface{}, error) {
+	workflow, trigger, err := testRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return engine.New(ctx.SQL, ctx.Logger).DryRun(ctx, *workflow, *trigger), nil
+}
+
+// PreviewStep dry-runs a workflow like TestRunWorkflow and returns only what the
+// named step did: its input, output and side effects, once per time it ran.
+func PreviewStep(ctx *gofr.Context) (interface{}, error) {
+	stepName := ctx.PathParam("step")
+	workflow, trigger, err := testRun(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := engine.New(ctx.SQL, ctx.Logger).DryRun(ctx, *workflow, *trigger)
+
+	runs := []engine.StepTrace{}
+	for _, step := range result.Steps {
+		if step.Step == stepName {
+			runs = append(runs, step)
+		}
+	}
+	if len(runs) == 0 && result.Error != "" {
+		return nil, fmt.Errorf("step %q did not run: %s", stepName, result.Error)
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("step %q did not run", stepName)
+	}
+
+	return map[string]interface{}{
+		"step": stepName,
+		"runs": runs,
+	}, nil
+}
+
+// testRun loads the workflow and the trigger of a test run request. Only the signed-in
+// owner of the workflow can test it, since the trigger can be one of its samples. Test
+// runs have no side effects, so the workflow's concurrency settings don't apply to them.
+func testRun(ctx *gofr.Context) (*engine.Workflow, *engine.Trigger, error) {
+	workflowID, err := strconv.Atoi(ctx.PathParam("id"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid workflow ID: %w", err)
+	}
+	if _, err := authRoutes.RequireWorkflowOwner(ctx, workflowID); err != nil {
+		return nil, nil, err
+	}
+
+	var requestBody struct {
+		Payload  map[string]interface{} `json:"payload"`
+		Headers  map[string]string      `json:"headers"`
+		SampleID int                    `json:"sampleId"`
+	}
+	// The body is optional; without one the workflow's sample is used
+	_ = ctx.Bind(&requestBody)
+
+	workflow, err := engine.LoadWorkflow(ctx, ctx.SQL, workflowID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if requestBody.Payload != nil {
+		return workflow, &engine.Trigger{
+			Type:        "webhook",
+			Body:        requestBody.Payload,
+			Headers:     requestBody.Headers,
+			ContentType: "application/json",
+		}, nil
+	}
+
+	var sample *engine.TriggerSample
+	if requestBody.SampleID != 0 {
+		sample, err = engine.LoadSample(ctx, ctx.SQL, workflowID, requestBody.SampleID)
+	} else {
+		sample, err = engine.DefaultSample(ctx, ctx.SQL, workflowID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if sample == nil {
+		return nil, nil, fmt.Errorf("a sample payload is required: workflow %d has not received any webhook calls yet", workflowID)
+	}
+	return workflow, &sample.Trigger, nil
+}
@@ -0,0 +1,207 @@
+package workflowRoutes
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github/Somnathumapathi/gofrhack/engine"
+	"github/Somnathumapathi/gofrhack/models"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxBodySize bounds the size of a non-multipart webhook body
+const maxBodySize = 16 << 20
+
+func badRequest(format string, args ...interface{}) error {
+	return models.StatusError{Status: http.StatusBadRequest, Message: fmt.Sprintf(format, args...)}
+}
+
+// readRequestTrigger decodes a webhook request into a trigger based on its Content-Type.
+// JSON objects, form fields and XML documents become the trigger body, newline-delimited
+// JSON becomes {"records": [...]} and text/csv becomes {"csv": "..."} for parse steps.
+// The raw body and headers are kept on the trigger either way.
+func readRequestTrigger(r *http.Request) (engine.Trigger, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		mediaType = ""
+	}
+
+	var trigger engine.Trigger
+	if boundary, ok := isMultipart(r); ok {
+		trigger, err = readMultipartTrigger(r, boundary)
+		if err != nil {
+			return engine.Trigger{}, badRequest("%v", err)
+		}
+	} else {
+		raw, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+		if err != nil {
+			return engine.Trigger{}, badRequest("failed to read request body: %v", err)
+		}
+
+		body, err := decodeBody(mediaType, raw)
+		if err != nil {
+			return engine.Trigger{}, badRequest("invalid %s body: %v", mediaType, err)
+		}
+
+		trigger = engine.Trigger{Type: "webhook", Body: body, Raw: raw}
+	}
+
+	trigger.ContentType = mediaType
+	trigger.Headers = make(map[string]string, len(r.Header))
+	for name, values := range r.Header {
+		trigger.Headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+
+	return trigger, nil
+}
+
+// decodeBody normalizes a raw body according to its media type
+func decodeBody(mediaType string, raw []byte) (interface{}, error) {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return map[string]interface{}{"triggerType": "webhook"}, nil
+	}
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return decodeForm(raw)
+	case mediaType == "text/csv":
+		return map[string]interface{}{"csv": string(raw)}, nil
+	case mediaType == "application/x-ndjson" || mediaType == "application/jsonl" || mediaType == "application/jsonlines":
+		return decodeNDJSON(raw)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return decodeXML(raw)
+	case mediaType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return decodeJSON(raw)
+	case strings.HasPrefix(mediaType, "text/"):
+		return map[string]interface{}{"text": string(raw)}, nil
+	default:
+		// Binary bodies are only available to steps through the raw trigger body
+		return map[string]interface{}{}, nil
+	}
+}
+
+func decodeJSON(raw []byte) (interface{}, error) {
+	var body interface{}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, err
+	}
+
+	switch value := body.(type) {
+	case map[string]interface{}:
+		return value, nil
+	case []interface{}:
+		return map[string]interface{}{"records": value}, nil
+	default:
+		return map[string]interface{}{"value": value}, nil
+	}
+}
+
+func decodeNDJSON(raw []byte) (interface{}, error) {
+	records := make([]interface{}, 0)
+	for i, line := range bytes.Split(raw, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var record interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		records = append(records, record)
+	}
+
+	return map[string]interface{}{"records": records}, nil
+}
+
+func decodeForm(raw []byte) (interface{}, error) {
+	values, err := url.ParseQuery(string(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{}, len(values))
+	for key, list := range values {
+		for _, value := range list {
+			addFormValue(fields, key, value)
+		}
+	}
+
+	return fields, nil
+}
+
+// decodeXML turns an XML document into nested maps. Attributes are stored under
+// "@name", text content under "#text" and repeated child elements become lists.
+func decodeXML(raw []byte) (interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("no root element")
+			}
+			return nil, err
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			root, err := decodeXMLElement(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: root}, nil
+		}
+	}
+}
+
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	element := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		element["@"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := decodeXMLElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			addXMLChild(element, t.Name.Local, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(element) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				element["#text"] = content
+			}
+			return element, nil
+		}
+	}
+}
+
+func addXMLChild(element map[string]interface{}, name string, child interface{}) {
+	switch existing := element[name].(type) {
+	case nil:
+		element[name] = child
+	case []interface{}:
+		element[name] = append(existing, child)
+	default:
+		element[name] = []interface{}{existing, child}
+	}
+}
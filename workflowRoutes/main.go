@@ -1,31 +1,29 @@
 package workflowRoutes
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"github/Somnathumapathi/gofrhack/engine"
 	"github/Somnathumapathi/gofrhack/models"
+	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
 	"gofr.dev/pkg/gofr"
 )
 
 type Workflow struct {
-	WebookUrl string      `json:"webhookUrl"`
-	Id        int         `json:"id"`
-	Steps     []Step      `json:"steps"`
-	Name      string      `json:"name"`
-	User      models.User `json:"users"`
-}
-
-type Step struct {
-	ID        int                    `json:"id"`
-	Name      string                 `json:"name"`
-	Type      string                 `json:"type"`
-	Payload   map[string]interface{} `json:"payload"`
-	StepOrder int                    `json:"stepOrder"`
+	WebookUrl string                `json:"webhookUrl"`
+	Id        int                   `json:"id"`
+	Steps     []engine.Step         `json:"steps"`
+	OnError   []engine.Step         `json:"onError,omitempty"`
+	Settings  engine.Settings       `json:"settings"`
+	Name      string                `json:"name"`
+	User      models.User           `json:"users"`
+	Sample    *engine.TriggerSample `json:"sample,omitempty"`
 }
 
 func GenerateWebhookUrl() (string, error) {
@@ -71,30 +69,27 @@ func CreateWorkflow(ctx *gofr.Context) (interface{}, error) {
 		return nil, err
 	}
 
+	// Reject step configurations and settings the engine cannot run
+	err = engine.ValidateWorkflow(engine.Workflow{Steps: workflow.Steps, OnError: workflow.OnError, Settings: workflow.Settings})
+	if err != nil {
+		return nil, fmt.Errorf("invalid workflow: %w", err)
+	}
+
 	webhookUrl, webhookUrlErr := GenerateWebhookUrl()
 	if webhookUrlErr != nil {
 		return nil, webhookUrlErr
 	}
 
-	query := `INSERT INTO workflows (name, webhook_url, user_id) VALUES ($1, $2, $3) RETURNING id`
-	err = ctx.SQL.QueryRowContext(ctx, query, workflow.Name, webhookUrl, uid).Scan(&workflow.Id)
+	query := `INSERT INTO workflows (name, webhook_url, user_id, settings) VALUES ($1, $2, $3, $4) RETURNING id`
+	err = ctx.SQL.QueryRowContext(ctx, query, workflow.Name, webhookUrl, uid, workflow.Settings).Scan(&workflow.Id)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, step := range workflow.Steps {
-		// Convert the map[string]string payload to JSON
-		payloadJSON, jsonErr := json.Marshal(step.Payload)
-		if jsonErr != nil {
-			return nil, jsonErr
-		}
-
-		stepQuery := `INSERT INTO steps (workflow_id, name, step_type, payload, step_order)
-                      VALUES ($1, $2, $3, $4, $5)`
-		_, err := ctx.SQL.ExecContext(ctx, stepQuery, workflow.Id, step.Name, step.Type, payloadJSON, step.StepOrder)
-		if err != nil {
-			return nil, err
-		}
+	// Store the steps, including the steps nested in branches
+	workflow.Steps, workflow.OnError, err = engine.SaveSteps(ctx, ctx.SQL, workflow.Id, workflow.Steps, workflow.OnError)
+	if err != nil {
+		return nil, err
 	}
 	workflow.WebookUrl = webhookUrl
 	return workflow, nil
@@ -132,76 +127,29 @@ func UpdateWorkflow(ctx *gofr.Context) (interface{}, error) {
 		return nil, fmt.Errorf("failed to bind workflow: %w", err)
 	}
 
-	// Update the workflow's name and webhook URL
-	updateWorkflowQuery := `UPDATE workflows SET name = $1, webhook_url = $2 WHERE id = $3`
-	_, err = ctx.SQL.ExecContext(ctx, updateWorkflowQuery, workflow.Name, workflow.WebookUrl, workflow.Id)
+	// Reject step configurations and settings the engine cannot run
+	err = engine.ValidateWorkflow(engine.Workflow{Steps: workflow.Steps, OnError: workflow.OnError, Settings: workflow.Settings})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update workflow: %w", err)
+		return nil, fmt.Errorf("invalid workflow: %w", err)
 	}
 
-	// Collect IDs of steps from the request
-	requestedStepIDs := make([]int, 0)
-	for _, step := range workflow.Steps {
-		if step.ID != 0 {
-			requestedStepIDs = append(requestedStepIDs, step.ID)
-		}
-	}
-
-	// Delete removed steps
-	err = DeleteRemovedSteps(ctx, workflow.Id, requestedStepIDs)
+	// Update the workflow's name, webhook URL and settings
+	updateWorkflowQuery := `UPDATE workflows SET name = $1, webhook_url = $2, settings = $3 WHERE id = $4`
+	_, err = ctx.SQL.ExecContext(ctx, updateWorkflowQuery, workflow.Name, workflow.WebookUrl, workflow.Settings, workflow.Id)
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete removed steps: %w", err)
+		return nil, fmt.Errorf("failed to update workflow: %w", err)
 	}
 
-	// Update or insert steps
-	for _, step := range workflow.Steps {
-		payloadJSON, err := json.Marshal(step.Payload)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal step payload: %w", err)
-		}
-
-		if step.ID != 0 {
-			// Update existing step
-			updateStepQuery := `UPDATE steps SET name = $1, step_type = $2, payload = $3, step_order = $4 WHERE id = $5`
-			_, err = ctx.SQL.ExecContext(ctx, updateStepQuery, step.Name, step.Type, string(payloadJSON), step.StepOrder, step.ID)
-			if err != nil {
-				return nil, fmt.Errorf("failed to update step with ID %d: %w", step.ID, err)
-			}
-		} else {
-			// Insert new step
-			insertStepQuery := `INSERT INTO steps (workflow_id, name, step_type, payload, step_order) VALUES ($1, $2, $3, $4, $5)`
-			_, err = ctx.SQL.ExecContext(ctx, insertStepQuery, workflow.Id, step.Name, step.Type, string(payloadJSON), step.StepOrder)
-			if err != nil {
-				return nil, fmt.Errorf("failed to insert new step: %w", err)
-			}
-		}
+	// Update or insert steps and delete the ones that were removed
+	workflow.Steps, workflow.OnError, err = engine.SaveSteps(ctx, ctx.SQL, workflow.Id, workflow.Steps, workflow.OnError)
+	if err != nil {
+		return nil, err
 	}
 
 	// Return the updated workflow
 	return workflow, nil
 }
 
-func DeleteRemovedSteps(ctx *gofr.Context, workflowID int, stepIDs []int) error {
-	if len(stepIDs) == 0 {
-		// If no steps are specified, delete all steps for this workflow
-		deleteQuery := `DELETE FROM steps WHERE workflow_id = $1`
-		_, err := ctx.SQL.ExecContext(ctx, deleteQuery, workflowID)
-		return err
-	}
-
-	// Convert the stepIDs slice into a comma-separated string
-	stepIDStrings := make([]string, len(stepIDs))
-	for i, id := range stepIDs {
-		stepIDStrings[i] = fmt.Sprintf("%d", id)
-	}
-	idList := strings.Join(stepIDStrings, ",") // e.g., "1,2,3"
-
-	// Use the constructed string in the SQL query
-	deleteQuery := fmt.Sprintf(`DELETE FROM steps WHERE workflow_id = $1 AND id NOT IN (%s)`, idList)
-	_, err := ctx.SQL.ExecContext(ctx, deleteQuery, workflowID)
-	return err
-}
-
 // func UpdateWorkflow(ctx *gofr.Context) (interface{}, error) {
 // 	var workflow Workflow
 
@@ -279,7 +227,7 @@ func GetWorkflows(ctx *gofr.Context) (interface{}, error) {
 	}
 
 	// Query to fetch all workflows associated with the user
-	query := `SELECT id, name, webhook_url FROM workflows WHERE user_id = $1`
+	query := `SELECT id, name, webhook_url, settings FROM workflows WHERE user_id = $1`
 	rows, err := ctx.SQL.QueryContext(ctx, query, uid)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch workflows: %w", err)
@@ -289,40 +237,17 @@ func GetWorkflows(ctx *gofr.Context) (interface{}, error) {
 	var workflows []Workflow
 	for rows.Next() {
 		var workflow Workflow
-		err := rows.Scan(&workflow.Id, &workflow.Name, &workflow.WebookUrl)
+		err := rows.Scan(&workflow.Id, &workflow.Name, &workflow.WebookUrl, &workflow.Settings)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse workflow data: %w", err)
 		}
 
-		// Fetch steps for this workflow
-		stepQuery := `SELECT id, name, step_type, payload, step_order FROM steps WHERE workflow_id = $1 ORDER BY step_order`
-		stepRows, err := ctx.SQL.QueryContext(ctx, stepQuery, workflow.Id)
+		// Fetch steps and error handler steps for this workflow
+		workflow.Steps, workflow.OnError, err = engine.LoadSteps(ctx, ctx.SQL, workflow.Id)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch steps for workflow %d: %w", workflow.Id, err)
 		}
 
-		var steps []Step
-		for stepRows.Next() {
-			var step Step
-			var payloadJSON string
-			err := stepRows.Scan(&step.ID, &step.Name, &step.Type, &payloadJSON, &step.StepOrder)
-			if err != nil {
-				stepRows.Close()
-				return nil, fmt.Errorf("failed to parse step data: %w", err)
-			}
-
-			// Deserialize the JSON payload
-			err = json.Unmarshal([]byte(payloadJSON), &step.Payload)
-			if err != nil {
-				stepRows.Close()
-				return nil, fmt.Errorf("failed to deserialize step payload: %w", err)
-			}
-
-			steps = append(steps, step)
-		}
-		stepRows.Close()
-
-		workflow.Steps = steps
 		workflows = append(workflows, workflow)
 	}
 
@@ -339,101 +264,213 @@ func GetWorkflow(ctx *gofr.Context) (interface{}, error) {
 
 	// Query to fetch the workflow details
 	var workflow Workflow
-	query := `SELECT id, name, webhook_url FROM workflows WHERE id = $1`
-	err := ctx.SQL.QueryRowContext(ctx, query, workflowID).Scan(&workflow.Id, &workflow.Name, &workflow.WebookUrl)
+	query := `SELECT id, name, webhook_url, settings FROM workflows WHERE id = $1`
+	err := ctx.SQL.QueryRowContext(ctx, query, workflowID).Scan(&workflow.Id, &workflow.Name, &workflow.WebookUrl, &workflow.Settings)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch workflow: %w", err)
 	}
 
-	// Query to fetch the steps associated with the workflowpackage
-	stepQuery := `SELECT id, name, step_type, payload, step_order FROM steps WHERE workflow_id = $1 ORDER BY step_order`
-	rows, err := ctx.SQL.QueryContext(ctx, stepQuery, workflowID)
+	// Fetch the steps and error handler steps associated with the workflow
+	workflow.Steps, workflow.OnError, err = engine.LoadSteps(ctx, ctx.SQL, workflow.Id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch steps for workflow: %w", err)
 	}
-	defer rows.Close()
 
-	// Parse the steps into the workflow object
-	var steps []Step
-	for rows.Next() {
-		var step Step
-		var payloadJSON string
-
-		// Scan the row into the Step struct
-		err := rows.Scan(&step.ID, &step.Name, &step.Type, &payloadJSON, &step.StepOrder)
+	// The sample test runs use: the pinned one, or the latest delivery. It holds a real
+	// delivery, so only the workflow's signed-in owner gets it.
+	if email, _ := ctx.Value("userEmail").(string); email != "" {
+		owned, err := engine.WorkflowOwnedBy(ctx, ctx.SQL, workflow.Id, email)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse step data: %w", err)
+			return nil, err
 		}
-
-		// Deserialize the JSON payload
-		err = json.Unmarshal([]byte(payloadJSON), &step.Payload)
-		if err != nil {
-			return nil, fmt.Errorf("failed to deserialize step payload: %w", err)
+		if owned {
+			workflow.Sample, err = engine.DefaultSample(ctx, ctx.SQL, workflow.Id)
+			if err != nil {
+				return nil, err
+			}
 		}
-
-		steps = append(steps, step)
 	}
 
-	// Attach the steps to the workflow
-	workflow.Steps = steps
-
 	// Return the workflow
 	return workflow, nil
 }
 
-// ExecuteWorkflow handles webhook execution
+// GetStepTypes lists the step and action types registered with the engine
+func GetStepTypes(ctx *gofr.Context) (interface{}, error) {
+	return map[string]interface{}{
+		"steps":   engine.StepTypes(),
+		"actions": engine.ActionTypes(),
+	}, nil
+}
+
+// ExecuteWorkflow handles webhook execution. Depending on the workflow's mode the run
+// happens inside the request, in the background with a 202 and an execution ID to
+// poll at /executions/{id}, or inside the request until syncTimeout and then in the
+// background.
 func ExecuteWorkflow(ctx *gofr.Context) (interface{}, error) {
 	workflowID := ctx.Request.PathParam("workflowId")
 	if workflowID == "" {
 		return nil, fmt.Errorf("workflow ID is required")
 	}
 
-	// Parse the incoming request body as JSON
-	var payload map[string]interface{}
-	err := ctx.Bind(&payload)
+	// Fetch workflow details and steps using webhook_url as the key
+	workflow, err := engine.LoadWorkflowByWebhook(ctx, ctx.SQL, workflowID)
 	if err != nil {
-		// If JSON binding fails, it might be a file upload, let's handle it as empty payload for now
-		payload = map[string]interface{}{
-			"triggerType": "webhook",
-		}
+		return nil, err
 	}
 
-	// Fetch workflow details using webhook_url as the key
-	var workflow Workflow
-	query := `SELECT id, name, webhook_url FROM workflows WHERE webhook_url = $1`
-	err = ctx.SQL.QueryRowContext(ctx, query, workflowID).Scan(&workflow.Id, &workflow.Name, &workflow.WebookUrl)
+	// Throttled calls are turned away before their body is read
+	if err := checkRateLimits(ctx, workflow); err != nil {
+		return nil, err
+	}
+
+	// Decode the trigger payload according to the request's Content-Type
+	trigger, err := readTrigger(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("workflow not found: %w", err)
+		return nil, err
+	}
+
+	// Keep the payload as a sample to test the workflow with; failing to do so does not
+	// fail the delivery
+	if err := engine.CaptureSample(ctx, ctx.SQL, workflow.ID, trigger); err != nil {
+		ctx.Logger.Errorf("Failed to capture trigger sample of workflow %d: %v", workflow.ID, err)
 	}
 
-	// Fetch workflow steps
-	stepQuery := `SELECT id, name, step_type, payload, step_order FROM steps WHERE workflow_id = $1 ORDER BY step_order`
-	rows, err := ctx.SQL.QueryContext(ctx, stepQuery, workflow.Id)
+	// A delivery the sender retries gets the outcome of the first one instead of a new run
+	key, err := engine.DeliveryKey(workflow.Settings, trigger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch workflow steps: %w", err)
+		trigger.RemoveFiles()
+		return nil, badRequest("%v", err)
+	}
+	if key != "" {
+		claimed, executionID, err := engine.ClaimDeliveryKey(ctx, ctx.SQL, workflow.ID, key, workflow.Settings.IdempotencyRetention())
+		if err != nil {
+			trigger.RemoveFiles()
+			return nil, err
+		}
+		if !claimed {
+			trigger.RemoveFiles()
+			return duplicateResponse(ctx, workflowID, key, executionID)
+		}
 	}
-	defer rows.Close()
 
-	var steps []Step
-	for rows.Next() {
-		var step Step
-		var payloadJSON string
-		err := rows.Scan(&step.ID, &step.Name, &step.Type, &payloadJSON, &step.StepOrder)
+	// Every run goes through the job queue, so it survives this instance going away
+	queue := engine.NewQueue(engine.New(ctx.SQL, ctx.Logger))
+	job, err := queue.Enqueue(ctx, *workflow, trigger)
+	trigger.RemoveFiles() // the job keeps its own copy of uploaded files
+	if key != "" {
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse step data: %w", err)
+			err = errors.Join(err, engine.ReleaseDeliveryKey(ctx, ctx.SQL, workflow.ID, key))
+		} else if linkErr := engine.LinkDeliveryKey(ctx, ctx.SQL, workflow.ID, key, job.ExecutionID); linkErr != nil {
+			ctx.Logger.Errorf("Execution %d will not be found by its idempotency key: %v", job.ExecutionID, linkErr)
 		}
+	}
+	switch {
+	case errors.Is(err, engine.ErrSkipped):
+		return map[string]interface{}{
+			"status":     "skipped",
+			"workflowId": workflowID,
+			"reason":     err.Error(),
+		}, nil
+	case errors.Is(err, engine.ErrConcurrencyLimit):
+		return nil, models.StatusError{Status: http.StatusTooManyRequests, Message: err.Error()}
+	case err != nil:
+		return nil, err
+	}
 
-		err = json.Unmarshal([]byte(payloadJSON), &step.Payload)
+	// Callers that wait for the result get the job run here. If another worker claimed
+	// it first, they get the execution to poll instead.
+	if mode := workflow.Settings.ExecutionMode(); mode != engine.ModeAsync {
+		claimed, err := queue.ClaimJob(ctx, job.ID)
 		if err != nil {
-			return nil, fmt.Errorf("invalid step payload: %w", err)
+			ctx.Logger.Infof("Not running job %d in the request: %v", job.ID, err)
+		} else {
+			done := make(chan runOutcome, 1)
+			go func() {
+				result, err := queue.Process(context.WithoutCancel(ctx), claimed)
+				done <- runOutcome{result: result, err: err}
+			}()
+
+			if mode == engine.ModeSync {
+				outcome := <-done
+				return workflowResponse(workflowID, outcome.result, outcome.err)
+			}
+
+			timer := time.NewTimer(workflow.Settings.SyncWait())
+			defer timer.Stop()
+			select {
+			case outcome := <-done:
+				return workflowResponse(workflowID, outcome.result, outcome.err)
+			case <-timer.C:
+			}
 		}
+	}
+
+	return acceptedResponse(ctx, workflowID, job.ExecutionID), nil
+}
+
+// acceptedResponse answers 202 with the execution to poll for a run that goes on in
+// the background
+func acceptedResponse(ctx *gofr.Context, workflowID string, executionID int) map[string]interface{} {
+	respondWithStatus(ctx, http.StatusAccepted)
+	return map[string]interface{}{
+		"status":      "accepted",
+		"workflowId":  workflowID,
+		"executionId": executionID,
+		"statusUrl":   fmt.Sprintf("/executions/%d", executionID),
+	}
+}
+
+// duplicateResponse answers a repeated delivery with the outcome of the original one:
+// its result once it has finished, or the execution to poll while it is still going
+func duplicateResponse(ctx *gofr.Context, workflowID, key string, executionID int) (interface{}, error) {
+	if executionID == 0 {
+		return nil, models.StatusError{Status: http.StatusConflict, Message: fmt.Sprintf("delivery %s is already being processed", key)}
+	}
 
-		steps = append(steps, step)
+	record, err := engine.LoadExecution(ctx, ctx.SQL, executionID)
+	if err != nil {
+		return nil, err
 	}
-	workflow.Steps = steps
+	ctx.Logger.Infof("Delivery %s of workflow %s is a duplicate of execution %d", key, workflowID, executionID)
+
+	var response map[string]interface{}
+	switch record.Status {
+	case "queued", "running":
+		response = acceptedResponse(ctx, workflowID, executionID)
+	case "success", "halted":
+		response = map[string]interface{}{
+			"status":      "success",
+			"workflowId":  workflowID,
+			"executionId": executionID,
+			"result":      record.Output,
+		}
+	default:
+		message := record.Status
+		if record.Message != nil {
+			message = *record.Message
+		}
+		return nil, fmt.Errorf("failed to execute workflow: %s (execution %d)", message, executionID)
+	}
+
+	response["duplicate"] = true
+	return response, nil
+}
 
-	// Execute the workflow
-	result, err := executeWorkflow(ctx, workflow, payload)
+// runOutcome is the result of a run executing in the background
+type runOutcome struct {
+	result interface{}
+	err    error
+}
+
+// workflowResponse is the response of a webhook whose run finished within the request
+func workflowResponse(workflowID string, result interface{}, err error) (interface{}, error) {
+	if errors.Is(err, engine.ErrCancelled) {
+		return nil, models.StatusError{Status: http.StatusConflict, Message: fmt.Sprintf("workflow run was %v", err)}
+	}
+	if errors.Is(err, engine.ErrTimedOut) {
+		return nil, models.StatusError{Status: http.StatusGatewayTimeout, Message: fmt.Sprintf("failed to execute workflow: %v", err)}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute workflow: %w", err)
 	}
@@ -445,35 +482,22 @@ func ExecuteWorkflow(ctx *gofr.Context) (interface{}, error) {
 	}, nil
 }
 
-// executeWorkflow processes the workflow steps
-func executeWorkflow(ctx *gofr.Context, workflow Workflow, input map[string]interface{}) (interface{}, error) {
-	intermediateData := input
-
-	for _, step := range workflow.Steps {
-		switch step.Type {
-		case "trigger":
-			// Trigger step - just pass through the data
-			fmt.Printf("Executing trigger step: %s\n", step.Name)
-		case "parse":
-			// Parse step - transform data based on payload configuration
-			fmt.Printf("Executing parse step: %s\n", step.Name)
-			if inputType, ok := step.Payload["inputType"].(string); ok {
-				if outputType, ok := step.Payload["outputType"].(string); ok {
-					fmt.Printf("Transforming from %s to %s\n", inputType, outputType)
-					// Here you would implement the actual transformation logic
-					// For now, just log what would happen
-				}
-			}
-		case "action":
-			// Action step - perform some action like API call, database insert, etc.
-			fmt.Printf("Executing action step: %s\n", step.Name)
-			// Here you would implement the actual action logic
-		default:
-			return nil, fmt.Errorf("unknown step type: %s", step.Type)
+// readTrigger builds the webhook trigger from the request body and headers
+func readTrigger(ctx *gofr.Context) (engine.Trigger, error) {
+	if r, ok := webhookRequest(ctx); ok {
+		return readRequestTrigger(r)
+	}
+
+	// Without the raw request only a JSON body can be read
+	var payload map[string]interface{}
+	err := ctx.Bind(&payload)
+	if err != nil {
+		payload = map[string]interface{}{
+			"triggerType": "webhook",
 		}
 	}
 
-	return intermediateData, nil
+	return engine.Trigger{Type: "webhook", Body: payload}, nil
 }
 
 // func webhookHandler(ctx *gofr.Context) (interface{}, error) {
@@ -570,145 +594,3 @@ func executeWorkflow(ctx *gofr.Context, workflow Workflow, input map[string]inte
 // 	// Use step.Payload for action-specific parameters
 // 	return nil
 // }
-
-// func webhookHandler(ctx *gofr.Context) (interface{}, error) {
-// 	workflowID := ctx.Param("workflowId")
-// 	var payload map[string]interface{}
-
-// 	// Parse the incoming multipart form-data
-// 	fileHeader, _, err := parseMultipartRequest(ctx)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("failed to parse multipart request: %w", err)
-// 	}
-
-// 	// Save the uploaded file temporarily
-// 	tempFilePath := "tmp/uploaded_file"
-// 	if fileHeader != nil {
-// 		err = saveUploadedFile(fileHeader, tempFilePath)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("error saving uploaded file: %w", err)
-// 		}
-// 		defer os.Remove(tempFilePath) // Clean up after use
-// 	}
-
-// 	// Fetch workflow details
-// 	var workflow Workflow
-// 	query := `SELECT id, name, webhook_url FROM workflows WHERE id = $1`
-// 	err = ctx.SQL.QueryRowContext(ctx, query, workflowID).Scan(&workflow.Id, &workflow.Name, &workflow.WebookUrl)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("workflow not found: %w", err)
-// 	}
-
-// 	// Fetch workflow steps
-// 	query = `SELECT id, name, step_type, payload, step_order FROM steps WHERE workflow_id = $1 ORDER BY step_order`
-// 	rows, err := ctx.SQL.QueryContext(ctx, query, workflowID)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("failed to fetch workflow steps: %w", err)
-// 	}
-// 	defer rows.Close()
-
-// 	steps := []Step{}
-// 	for rows.Next() {
-// 		var step Step
-// 		var payloadJSON string
-// 		err = rows.Scan(&step.ID, &step.Name, &step.Type, &payloadJSON, &step.StepOrder)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("failed to parse step data: %w", err)
-// 		}
-
-// 		err = json.Unmarshal([]byte(payloadJSON), &step.Payload)
-// 		if err != nil {
-// 			return nil, fmt.Errorf("invalid step payload: %w", err)
-// 		}
-
-// 		steps = append(steps, step)
-// 	}
-// 	workflow.Steps = steps
-
-// 	// Execute the workflow with the uploaded file or other payload data
-// 	err = executeWorkflow(ctx, workflow, payload, tempFilePath)
-// 	if err != nil {
-// 		return nil, fmt.Errorf("failed to execute workflow: %w", err)
-// 	}
-
-// 	return "Workflow executed suc	cessfully", nil
-// }
-
-// // Parses a multipart request
-// func parseMultipartRequest(ctx *gofr.Context) (*multipart.FileHeader, map[string]interface{}, error) {
-// 	var payload map[string]interface{}
-// 	err := ctx.Bind(&payload)
-// 	if err != nil {
-// 		return nil, nil, fmt.Errorf("invalid webhook payload: %w", err)
-// 	}
-
-// 	fileHeader := ctx.FileHeader("file")
-// 	return fileHeader, payload, nil
-// }
-
-// // Saves an uploaded file to the local filesystem
-// func saveUploadedFile(fileHeader *multipart.FileHeader, filePath string) error {
-// 	file, err := fileHeader.Open()
-// 	if err != nil {
-// 		return fmt.Errorf("error opening uploaded file: %w", err)
-// 	}
-// 	defer file.Close()
-
-// 	content, err := io.ReadAll(file)
-// 	if err != nil {
-// 		return fmt.Errorf("error reading uploaded file content: %w", err)
-// 	}
-
-// 	err = os.WriteFile(filePath, content, 0644)
-// 	if err != nil {
-// 		return fmt.Errorf("error saving uploaded file: %w", err)
-// 	}
-// 	return nil
-// }
-
-// // Executes the workflow with the provided input data and steps
-// func executeWorkflow(ctx *gofr.Context, workflow Workflow, input map[string]interface{}, tempFilePath string) error {
-// 	intermediateData := input
-
-// 	for _, step := range workflow.Steps {
-// 		var err error
-// 		switch step.Type {
-// 		case "trigger":
-// 			intermediateData, err = executeTrigger(step, intermediateData)
-// 		case "parse":
-// 			intermediateData, err = executeParse(step, intermediateData, tempFilePath)
-// 		case "action":
-// 			err = executeAction(step, intermediateData)
-// 		default:
-// 			return fmt.Errorf("unknown step type: %s", step.Type)
-// 		}
-
-// 		if err != nil {
-// 			return fmt.Errorf("step '%s' failed: %w", step.Name, err)
-// 		}
-// 	}
-
-// 	return nil
-// }
-
-// // Trigger step execution
-// func executeTrigger(step Step, input map[string]interface{}) (map[string]interface{}, error) {
-// 	// Process trigger logic
-// 	return input, nil
-// }
-
-// // Parse step execution
-// func executeParse(step Step, input map[string]interface{}, filePath string) (map[string]interface{}, error) {
-// 	// Example: Convert JSON to CSV, or process file-specific logic
-// 	if _, ok := step.Payload["format"].(string); ok {
-// 		fmt.Printf("Parsing file %s with format %s\n", filePath, step.Payload["format"].(string))
-// 	}
-// 	return input, nil
-// }
-
-// // Action step execution
-// func executeAction(step Step, input map[string]interface{}) error {
-// 	// Example: Make API calls or perform specific actions
-// 	fmt.Printf("Performing action '%s' with payload %v\n", step.Name, step.Payload)
-// 	return nil
-// }
@@ -0,0 +1,264 @@
+package workflowRoutes
+
+import (
+	"context"
+	"fmt"
+	"github/Somnathumapathi/gofrhack/engine"
+	"github/Somnathumapathi/gofrhack/models"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gofr.dev/pkg/gofr"
+)
+
+// RateLimitedMetric counts webhook calls rejected by a rate limit
+const RateLimitedMetric = "webhook_rate_limited_total"
+
+// maxLocalBuckets is how many buckets the in-memory limiter keeps before it drops
+// the ones that have refilled completely
+const maxLocalBuckets = 10000
+
+// tokenBucketScript takes a token from each bucket in KEYS, given each bucket's rate
+// in tokens per millisecond and capacity as pairs in ARGV. Tokens are only taken when
+// every bucket has one. It returns {0, 0} when they were taken, or the 1-based index
+// of the bucket with the longest wait and how many milliseconds it is. Redis' clock
+// is used so instances agree on the time.
+const tokenBucketScript = `
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000 + math.floor(tonumber(clock[2]) / 1000)
+local tokens = {}
+local blocked, wait = 0, 0
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[i * 2 - 1])
+	local capacity = tonumber(ARGV[i * 2])
+	local state = redis.call('HMGET', key, 'tokens', 'ts')
+	local ts = tonumber(state[2]) or now
+	tokens[i] = math.min(capacity, (tonumber(state[1]) or capacity) + math.max(0, now - ts) * rate)
+	if tokens[i] < 1 then
+		local w = math.ceil((1 - tokens[i]) / rate)
+		if w > wait then
+			blocked, wait = i, w
+		end
+	end
+end
+for i, key in ipairs(KEYS) do
+	local rate = tonumber(ARGV[i * 2 - 1])
+	local capacity = tonumber(ARGV[i * 2])
+	if blocked == 0 then
+		tokens[i] = tokens[i] - 1
+	end
+	redis.call('HSET', key, 'tokens', tostring(tokens[i]), 'ts', now)
+	redis.call('PEXPIRE', key, math.ceil(capacity / rate) + 1000)
+end
+return {blocked, wait}
+`
+
+// bucket is a token bucket of the in-memory limiter
+type bucket struct {
+	tokens   float64
+	updated  time.Time
+	rate     float64
+	capacity float64
+}
+
+// localBuckets limits calls within this instance when no Redis is configured
+var localBuckets = struct {
+	sync.Mutex
+	buckets map[string]*bucket
+}{buckets: make(map[string]*bucket)}
+
+// trustedProxies are the proxies whose X-Forwarded-For header is believed
+var trustedProxies []*net.IPNet
+
+// TrustProxies sets the proxies, as a comma-separated list of addresses or CIDR
+// ranges, whose X-Forwarded-For header is used to find where a webhook call came
+// from. Calls from anywhere else are limited by the address they connect from.
+func TrustProxies(list string) error {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	trustedProxies = proxies
+	return nil
+}
+
+// rateLimit is one of the buckets a webhook call takes a token from
+type rateLimit struct {
+	scope string
+	key   string
+	limit engine.RateLimit
+}
+
+// checkRateLimits applies a workflow's per-IP and per-workflow rate limits to a
+// webhook call. The call takes a token from every bucket or from none, so a call
+// rejected by one limit does not use up the other. A rejected call gets 429 with
+// Retry-After and is counted in metrics.
+func checkRateLimits(ctx *gofr.Context, workflow *engine.Workflow) error {
+	var limits []rateLimit
+	if l := workflow.Settings.IPRateLimit; l != nil {
+		limits = append(limits, rateLimit{"ip", fmt.Sprintf("hookit:ratelimit:workflow:%d:ip:%s", workflow.ID, sourceIP(ctx)), *l})
+	}
+	if l := workflow.Settings.RateLimit; l != nil {
+		limits = append(limits, rateLimit{"workflow", fmt.Sprintf("hookit:ratelimit:workflow:%d", workflow.ID), *l})
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	blocked, wait := takeTokens(ctx, limits)
+	if wait <= 0 {
+		return nil
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	setHeader(ctx, "Retry-After", strconv.Itoa(retryAfter))
+	ctx.Metrics().IncrementCounter(ctx, RateLimitedMetric, "workflow", strconv.Itoa(workflow.ID), "scope", limits[blocked].scope)
+	return models.StatusError{
+		Status:  http.StatusTooManyRequests,
+		Message: fmt.Sprintf("rate limit of workflow %d exceeded; retry after %d seconds", workflow.ID, retryAfter),
+	}
+}
+
+// takeTokens takes a token from every bucket when each has one and returns a zero
+// wait. Otherwise it takes none and returns the index of the bucket with the longest
+// wait and how long it is. Buckets live in Redis when it is configured, so every
+// instance shares them; without Redis, or when it fails, each instance limits on its own.
+func takeTokens(ctx *gofr.Context, limits []rateLimit) (int, time.Duration) {
+	if ctx.Redis != nil {
+		keys := make([]string, 0, len(limits))
+		args := make([]interface{}, 0, 2*len(limits))
+		for _, l := range limits {
+			keys = append(keys, l.key)
+			args = append(args, l.limit.Rate()/1000, l.limit.Capacity())
+		}
+
+		result, err := ctx.Redis.Eval(ctx, tokenBucketScript, keys, args...).Int64Slice()
+		if err == nil && len(result) == 2 {
+			if result[0] == 0 {
+				return 0, 0
+			}
+			return int(result[0]) - 1, time.Duration(result[1]) * time.Millisecond
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected result %v", result)
+		}
+		ctx.Logger.Errorf("Failed to check rate limits %v in Redis, limiting locally: %v", keys, err)
+	}
+
+	return takeLocalTokens(limits, time.Now())
+}
+
+// takeLocalTokens is takeTokens for the in-memory buckets
+func takeLocalTokens(limits []rateLimit, now time.Time) (int, time.Duration) {
+	localBuckets.Lock()
+	defer localBuckets.Unlock()
+
+	if len(localBuckets.buckets) >= maxLocalBuckets {
+		for k, b := range localBuckets.buckets {
+			if b.tokens+now.Sub(b.updated).Seconds()*b.rate >= b.capacity {
+				delete(localBuckets.buckets, k)
+			}
+		}
+	}
+
+	buckets := make([]*bucket, len(limits))
+	blocked, wait := 0, time.Duration(0)
+	for i, l := range limits {
+		rate := l.limit.Rate()
+		capacity := float64(l.limit.Capacity())
+
+		b, ok := localBuckets.buckets[l.key]
+		if !ok {
+			b = &bucket{tokens: capacity, updated: now}
+			localBuckets.buckets[l.key] = b
+		}
+		b.rate, b.capacity = rate, capacity
+		b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+		b.updated = now
+		buckets[i] = b
+
+		if b.tokens < 1 {
+			if w := time.Duration((1 - b.tokens) / rate * float64(time.Second)); w > wait {
+				blocked, wait = i, w
+			}
+		}
+	}
+
+	if wait > 0 {
+		return blocked, wait
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return 0, 0
+}
+
+// sourceIP returns the address a webhook call came from. That is the address it
+// connects from, unless it connects from a trusted proxy: then it is the last address
+// in X-Forwarded-For that is not a trusted proxy itself. Entries before that can be
+// forged by the caller.
+func sourceIP(ctx context.Context) string {
+	r, ok := webhookRequest(ctx)
+	if !ok {
+		return "unknown"
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// isTrustedProxy reports whether an address belongs to a trusted proxy
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
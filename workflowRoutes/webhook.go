@@ -0,0 +1,183 @@
+package workflowRoutes
+
+import (
+	"context"
+	"fmt"
+	"github/Somnathumapathi/gofrhack/engine"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// maxUploadSize bounds the size of a single multipart webhook request
+const maxUploadSize = 64 << 20
+
+type webhookRequestKey struct{}
+
+type webhookWriterKey struct{}
+
+// WebhookMiddleware keeps the raw request of webhook calls in the request context.
+// gofr's Bind only understands a few body formats and hides the headers, so
+// ExecuteWorkflow reads the headers and body itself. It also lets ExecuteWorkflow
+// choose the status of a successful response.
+func WebhookMiddleware() func(handler http.Handler) http.Handler {
+	return func(inner http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasPrefix(r.URL.Path, "/webhook/") {
+				inner.ServeHTTP(w, r)
+				return
+			}
+
+			writer := &webhookWriter{ResponseWriter: w}
+			newContext := context.WithValue(r.Context(), webhookRequestKey{}, r)
+			newContext = context.WithValue(newContext, webhookWriterKey{}, writer)
+			inner.ServeHTTP(writer, r.WithContext(newContext))
+		})
+	}
+}
+
+// webhookWriter replaces the status gofr sends for a successful webhook response,
+// which is always 201 for POST requests, with the one chosen by the handler
+type webhookWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *webhookWriter) WriteHeader(code int) {
+	if w.status != 0 && code < http.StatusBadRequest {
+		code = w.status
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// respondWithStatus sets the status of a successful webhook response, such as 202
+func respondWithStatus(ctx context.Context, status int) {
+	if w, ok := ctx.Value(webhookWriterKey{}).(*webhookWriter); ok {
+		w.status = status
+	}
+}
+
+// setHeader sets a header of the webhook response, such as Retry-After
+func setHeader(ctx context.Context, name, value string) {
+	if w, ok := ctx.Value(webhookWriterKey{}).(*webhookWriter); ok {
+		w.Header().Set(name, value)
+	}
+}
+
+// webhookRequest returns the raw request stored by WebhookMiddleware, if any
+func webhookRequest(ctx context.Context) (*http.Request, bool) {
+	r, ok := ctx.Value(webhookRequestKey{}).(*http.Request)
+	return r, ok
+}
+
+// isMultipart reports whether the request carries multipart/form-data and returns its boundary
+func isMultipart(r *http.Request) (string, bool) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return "", false
+	}
+	return params["boundary"], params["boundary"] != ""
+}
+
+// readMultipartTrigger reads form fields and spools uploaded files to temporary storage.
+// The caller must call RemoveFiles on the returned trigger once the run is over.
+func readMultipartTrigger(r *http.Request, boundary string) (engine.Trigger, error) {
+	trigger := engine.Trigger{Type: "webhook", Files: make(map[string]engine.File)}
+	fields := make(map[string]interface{})
+
+	reader := multipart.NewReader(http.MaxBytesReader(nil, r.Body, maxUploadSize), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			trigger.RemoveFiles()
+			return engine.Trigger{}, fmt.Errorf("invalid multipart body: %w", err)
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(part)
+			part.Close()
+			if err != nil {
+				trigger.RemoveFiles()
+				return engine.Trigger{}, fmt.Errorf("failed to read form field %s: %w", part.FormName(), err)
+			}
+			addFormValue(fields, part.FormName(), string(value))
+			continue
+		}
+
+		file, err := spoolUpload(part)
+		part.Close()
+		if err != nil {
+			trigger.RemoveFiles()
+			return engine.Trigger{}, err
+		}
+
+		// A field may carry several files, so later ones get a numbered name
+		name := file.Field
+		for i := 2; ; i++ {
+			if _, taken := trigger.Files[name]; !taken {
+				break
+			}
+			name = fmt.Sprintf("%s_%d", file.Field, i)
+		}
+		trigger.Files[name] = file
+	}
+
+	files := make(map[string]interface{}, len(trigger.Files))
+	for name, file := range trigger.Files {
+		files[name] = map[string]interface{}{
+			"filename":    file.Filename,
+			"size":        file.Size,
+			"contentType": file.ContentType,
+		}
+	}
+	fields["files"] = files
+	trigger.Body = fields
+
+	return trigger, nil
+}
+
+// spoolUpload copies a file part to a temporary file
+func spoolUpload(part *multipart.Part) (engine.File, error) {
+	tmp, err := os.CreateTemp("", "hookit-upload-*")
+	if err != nil {
+		return engine.File{}, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, part)
+	if err != nil {
+		os.Remove(tmp.Name())
+		return engine.File{}, fmt.Errorf("failed to save uploaded file %s: %w", part.FileName(), err)
+	}
+
+	contentType := part.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	return engine.File{
+		Field:       part.FormName(),
+		Filename:    part.FileName(),
+		Size:        size,
+		ContentType: contentType,
+		Path:        tmp.Name(),
+	}, nil
+}
+
+// addFormValue stores a form value, turning repeated keys into a list
+func addFormValue(fields map[string]interface{}, key, value string) {
+	switch existing := fields[key].(type) {
+	case nil:
+		fields[key] = value
+	case []interface{}:
+		fields[key] = append(existing, value)
+	default:
+		fields[key] = []interface{}{existing, value}
+	}
+}